@@ -0,0 +1,191 @@
+package bookgen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/JessebotX/bookgen/internal/links"
+)
+
+// assetSet holds the names of the files of a book that are copied
+// into its output directory, relative to the book directory. It is
+// shared between the book and its chapters.
+type assetSet struct {
+	mu    sync.Mutex
+	src   source
+	dir   string
+	names map[string]struct{}
+}
+
+func newAssetSet(src source, dir string) *assetSet {
+	return &assetSet{
+		src:   src,
+		dir:   dir,
+		names: make(map[string]struct{}),
+	}
+}
+
+func (s *assetSet) add(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.names[path.Clean(filepath.ToSlash(name))] = struct{}{}
+}
+
+// Add all files in the directory at name (relative to the book
+// directory), except markdown files. Nothing is added if the
+// directory does not exist.
+func (s *assetSet) addDir(name string) error {
+	root := path.Join(s.dir, name)
+	err := fs.WalkDir(s.src.fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || path.Ext(p) == ".md" {
+			return nil
+		}
+
+		rel, ok := relativePath(s.dir, p)
+		if !ok {
+			return fmt.Errorf("file `%v` is outside of the book directory", s.src.path(p))
+		}
+
+		s.add(rel)
+		return nil
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// Remove the files in names from the set, except those in keep and
+// those in the assets directory. Returns the names of removed files.
+func (s *assetSet) remove(names, keep []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []string
+	for _, name := range names {
+		if slices.Contains(keep, name) || strings.HasPrefix(name, "assets/") {
+			continue
+		}
+
+		if _, ok := s.names[name]; ok {
+			delete(s.names, name)
+			removed = append(removed, name)
+		}
+	}
+
+	return removed
+}
+
+// Sorted names of all files in the set.
+func (s *assetSet) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Returns a links.Rewriter for markdown content in sourceDir (a
+// directory in the same filesystem as the book directory), which
+// rewrites relative destinations to be relative to the book's output
+// directory and adds the referenced files to the set. Links to
+// markdown files of the book and its chapters are rewritten to their
+// pages. If used is not nil, the names of referenced files are
+// appended to it, and if chapters is not nil, the page names of linked
+// chapters are appended to it.
+func (s *assetSet) rewriter(sourceDir string, used, chapters *[]string) links.Rewriter {
+	return func(destination string) (string, error) {
+		u, err := url.Parse(destination)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || u.Path == "" || path.IsAbs(u.Path) {
+			return destination, nil
+		}
+
+		target := path.Join(sourceDir, u.Path)
+		name, ok := relativePath(s.dir, target)
+		if !ok {
+			// Outside of the book, e.g. another book of the collection.
+			return destination, nil
+		}
+
+		u.RawPath = ""
+
+		// The book page exists even without an index.md
+		if name == "index.md" {
+			u.Path = "index.html"
+			return u.String(), nil
+		}
+
+		info, err := fs.Stat(s.src.fsys, target)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("file `%v` does not exist", s.src.path(target))
+			}
+			return "", err
+		}
+
+		if path.Ext(name) == ".md" {
+			pageName, ok := pageNameFromPath(name)
+			if !ok {
+				return "", fmt.Errorf("file `%v` is not a chapter", s.src.path(target))
+			}
+
+			if chapters != nil && !slices.Contains(*chapters, pageName) {
+				*chapters = append(*chapters, pageName)
+			}
+
+			u.Path = pageName + ".html"
+			return u.String(), nil
+		}
+
+		if info.IsDir() {
+			return "", fmt.Errorf("file `%v` is a directory", s.src.path(target))
+		}
+
+		s.add(name)
+		if used != nil && !slices.Contains(*used, name) {
+			*used = append(*used, name)
+		}
+
+		u.Path = name
+		return u.String(), nil
+	}
+}
+
+// Returns the page name of a markdown file at name (relative to the
+// book directory), which is either a chapter (`chapters/NAME.md`) or
+// a chapter bundle (`chapters/NAME/index.md`).
+func pageNameFromPath(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, "chapters/")
+	if !ok {
+		return "", false
+	}
+
+	if dir, ok := strings.CutSuffix(rest, "/index.md"); ok && !strings.Contains(dir, "/") {
+		return dir, true
+	}
+
+	if !strings.Contains(rest, "/") {
+		return strings.TrimSuffix(rest, ".md"), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Valid values for --log-format.
+var LogFormatValidValues = []string{"text", "json"}
+
+// Logger for all messages of the program, replaced in main once the
+// CLI arguments are read.
+var logger = slog.New(newTextHandler(os.Stderr, slog.LevelWarn, nil))
+
+// Create the logger for the CLI arguments. Warnings are shown by
+// default, unless non-essential output is disabled.
+func newLogger(opts *Opts, progress *progressLine) (*slog.Logger, error) {
+	level := slog.LevelWarn
+	if opts.Debug {
+		level = slog.LevelDebug
+	} else if opts.Verbose {
+		level = slog.LevelInfo
+	} else if opts.NoNonEssentialOutput {
+		level = slog.LevelError
+	}
+
+	switch opts.LogFormat {
+	case "", "text":
+		return slog.New(newTextHandler(os.Stderr, level, progress)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})), nil
+	}
+
+	return nil, fmt.Errorf("invalid log format `%v`. Must be one of the following options: %v.", opts.LogFormat, strings.Join(LogFormatValidValues, " | "))
+}
+
+// textHandler is a slog.Handler that writes messages for people, such
+// as `bookgen warning: message key=value`.
+type textHandler struct {
+	mu       *sync.Mutex
+	w        io.Writer
+	level    slog.Leveler
+	progress *progressLine
+	attrs    []slog.Attr
+	group    string
+}
+
+// progress is cleared before writing a message, and may be nil.
+func newTextHandler(w io.Writer, level slog.Leveler, progress *progressLine) *textHandler {
+	return &textHandler{
+		mu:       &sync.Mutex{},
+		w:        w,
+		level:    level,
+		progress: progress,
+	}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var prefix string
+	switch {
+	case r.Level >= slog.LevelError:
+		prefix = "bookgen error: "
+	case r.Level >= slog.LevelWarn:
+		prefix = "bookgen warning: "
+	case r.Level >= slog.LevelInfo:
+		prefix = "bookgen: "
+	default:
+		prefix = "bookgen debug: "
+	}
+
+	var sb strings.Builder
+	sb.WriteString(terminalPrintBold(prefix))
+	sb.WriteString(r.Message)
+
+	for _, attr := range h.attrs {
+		writeAttr(&sb, "", attr)
+	}
+
+	r.Attrs(func(attr slog.Attr) bool {
+		writeAttr(&sb, h.group, attr)
+		return true
+	})
+	sb.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.progress != nil {
+		h.progress.clear()
+	}
+
+	_, err := io.WriteString(h.w, sb.String())
+	return err
+}
+
+func writeAttr(sb *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := attr.Key
+	if group != "" {
+		key = group + "." + key
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			writeAttr(sb, key, a)
+		}
+		return
+	}
+
+	value := attr.Value.String()
+	if strings.ContainsAny(value, " \t\n\"=") || value == "" {
+		value = fmt.Sprintf("%q", value)
+	}
+
+	fmt.Fprintf(sb, " %v=%v", key, value)
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		h2.attrs = append(h2.attrs, attr)
+	}
+
+	return &h2
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	if h.group != "" {
+		h2.group = h.group + "." + name
+	} else {
+		h2.group = name
+	}
+
+	return &h2
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
-	"time"
+
+	// Time zones of release schedules, on systems without the
+	// time zone database
+	_ "time/tzdata"
 
 	"github.com/JessebotX/bookgen"
+	"github.com/JessebotX/bookgen/render"
 )
 
 var (
@@ -16,17 +24,56 @@ var (
 )
 
 type Opts struct {
-	Help                 bool      `long:"help" short:"h" desc:"Print help/usage information"`
-	Version              bool      `long:"version" short:"v" desc:"Print program version"`
-	PlainOutput          bool      `long:"plain" desc:"Remove terminal escape codes from printing into stdout/stderr"`
-	NoNonEssentialOutput bool      `long:"no-non-essential-output" short:"q" desc:"Prevent printing non-error messages into stdout/stderr"`
-	BuildCommand         BuildOpts `subcommand:"build" desc:"build source files"`
+	Help                 bool         `long:"help" short:"h" desc:"Print help/usage information"`
+	Version              bool         `long:"version" short:"v" desc:"Print program version"`
+	PlainOutput          bool         `long:"plain" desc:"Remove terminal escape codes from printing into stdout/stderr"`
+	NoNonEssentialOutput bool         `long:"no-non-essential-output" short:"q" desc:"Prevent printing non-error messages into stdout/stderr"`
+	Verbose              bool         `long:"verbose" desc:"Print informational messages, such as each book decoded"`
+	Debug                bool         `long:"debug" desc:"Print debugging messages, such as each file written"`
+	LogFormat            string       `long:"log-format" desc:"Format of messages printed into stderr: text (default) | json"`
+	BuildCommand         BuildOpts    `subcommand:"build" desc:"build source files"`
+	ValidateCommand      ValidateOpts `subcommand:"validate" desc:"check configuration files and front matter against the schema"`
+	SchemaCommand        SchemaOpts   `subcommand:"schema" desc:"print the JSON Schema of configuration files and front matter"`
+	MigrateCommand       MigrateOpts  `subcommand:"migrate" desc:"update configuration files and front matter to the current format version"`
+	StatsCommand         StatsOpts    `subcommand:"stats" desc:"print word counts, reading times and other statistics of books and chapters"`
 }
 
 type BuildOpts struct {
-	Minify          bool   `long:"minify" desc:"Minify output/distributable files"`
-	InputDirectory  string `long:"input-directory" short:"i" desc:"Directory containing source files with a bookgen.yml"`
-	OutputDirectory string `long:"output-directory" short:"o" desc:"Directory to output distributable files"`
+	Minify          bool     `long:"minify" desc:"Minify output/distributable files"`
+	Lenient         bool     `long:"lenient" desc:"Warn about unknown keys in configuration files and front matter instead of failing"`
+	Environment     string   `long:"environment" short:"e" desc:"Merge the configuration files of an environment, e.g. bookgen.production.yml, over bookgen.yml"`
+	Set             []string `long:"set" desc:"Override a key of the collection's configuration as key=value (repeatable), after BOOKGEN_* environment variables"`
+	Drafts          bool     `long:"drafts" desc:"Include books and chapters with draft: true"`
+	Future          bool     `long:"future" desc:"Include books and chapters with a published date in the future"`
+	Expired         bool     `long:"expired" desc:"Include books and chapters with an expires date in the past"`
+	Format          string   `long:"format" short:"f" desc:"Output format: website (default) | json"`
+	InputDirectory  string   `long:"input-directory" short:"i" desc:"Directory containing source files with a bookgen.yml/.toml/.json"`
+	OutputDirectory string   `long:"output-directory" short:"o" desc:"Directory to output distributable files"`
+}
+
+type ValidateOpts struct {
+	InputDirectory string `long:"input-directory" short:"i" desc:"Directory containing source files with a bookgen.yml/.toml/.json"`
+	Environment    string `long:"environment" short:"e" desc:"Also check the configuration files of an environment, e.g. bookgen.production.yml"`
+}
+
+type SchemaOpts struct {
+	Kind            string `long:"kind" short:"k" desc:"Schema to print: collection (default) | book | chapter"`
+	OutputDirectory string `long:"output-directory" short:"o" desc:"Write all schemas into a directory instead (bookgen.schema.json, bookgen-book.schema.json, chapter.schema.json)"`
+}
+
+type MigrateOpts struct {
+	InputDirectory string `long:"input-directory" short:"i" desc:"Directory containing source files with a bookgen.yml/.toml/.json"`
+	DryRun         bool   `long:"dry-run" short:"n" desc:"Print the changes without writing any files"`
+}
+
+type StatsOpts struct {
+	InputDirectory string `long:"input-directory" short:"i" desc:"Directory containing source files with a bookgen.yml/.toml/.json"`
+	Environment    string `long:"environment" short:"e" desc:"Merge the configuration files of an environment, e.g. bookgen.production.yml, over bookgen.yml"`
+	Format         string `long:"format" short:"f" desc:"Output format: table (default) | json"`
+	Lenient        bool   `long:"lenient" desc:"Warn about unknown keys in configuration files and front matter instead of failing"`
+	Drafts         bool   `long:"drafts" desc:"Include books and chapters with draft: true"`
+	Future         bool   `long:"future" desc:"Include books and chapters with a published date in the future"`
+	Expired        bool   `long:"expired" desc:"Include books and chapters with an expires date in the past"`
 }
 
 type HelpOpts struct {
@@ -40,7 +87,7 @@ func main() {
 	var opts Opts
 	command, _, err := OptsParse(&opts, os.Args)
 	if err != nil {
-		errorExit(1, err.Error())
+		errorExit(1, "%v", err)
 	}
 
 	// ---
@@ -48,14 +95,40 @@ func main() {
 	// ---
 	EnablePlainOutput = opts.PlainOutput
 
+	// Show a progress line while building, unless other messages are
+	// printed for each step
+	var progress *progressLine
+	if command == "build" && !opts.NoNonEssentialOutput && !opts.Verbose && !opts.Debug && opts.LogFormat != "json" {
+		progress = newProgressLine(os.Stderr)
+	}
+
+	logger, err = newLogger(&opts, progress)
+	if err != nil {
+		logger = slog.New(newTextHandler(os.Stderr, slog.LevelWarn, nil))
+		errorExit(1, "%v", err)
+	}
+
 	// ---
 	// Parse collection
 	// ---
 	if opts.Help {
-		if command == "build" {
+		switch command {
+		case "build":
 			var buildOpts BuildOpts
 			OptsWriteHelpSubcommand(os.Stdout, &buildOpts, "bookgen build [flags...]")
-		} else {
+		case "validate":
+			var validateOpts ValidateOpts
+			OptsWriteHelpSubcommand(os.Stdout, &validateOpts, "bookgen validate [flags...]")
+		case "schema":
+			var schemaOpts SchemaOpts
+			OptsWriteHelpSubcommand(os.Stdout, &schemaOpts, "bookgen schema [flags...]")
+		case "migrate":
+			var migrateOpts MigrateOpts
+			OptsWriteHelpSubcommand(os.Stdout, &migrateOpts, "bookgen migrate [flags...]")
+		case "stats":
+			var statsOpts StatsOpts
+			OptsWriteHelpSubcommand(os.Stdout, &statsOpts, "bookgen stats [flags...]")
+		default:
 			OptsWriteHelp(os.Stdout, &opts, "bookgen <command> [flags...]")
 		}
 
@@ -68,56 +141,196 @@ func main() {
 		outputDirectory := opts.BuildCommand.OutputDirectory
 		enableMinify := opts.BuildCommand.Minify
 
+		format := render.Format(opts.BuildCommand.Format)
+		if format == "" {
+			format = render.FormatWebsite
+		}
+
 		// Default output directory is relative to the input directory.
 		if outputDirectory == "" {
 			outputDirectory = filepath.Join(inputDirectory, "out")
 		}
 
-		// output dir cannot be == input dir
-		if filepath.Clean(inputDirectory) == filepath.Clean(outputDirectory) {
-			errorExit(1, "output directory cannot be equal to the working/input directory (`%s` and `%s` are the same).", inputDirectory, outputDirectory)
+		// Environment variables are overridden by --set
+		overrides := bookgen.EnvOverrides(os.Environ())
+		for _, set := range opts.BuildCommand.Set {
+			override, err := bookgen.ParseOverride(set, "--set")
+			if err != nil {
+				errorExit(1, "%v", err)
+			}
+			overrides = append(overrides, override)
 		}
 
-		totalTimeStart := time.Now()
-		decodeTimeStart := time.Now()
+		// Stop the build on Ctrl+C
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
 
-		collection, err := bookgen.DecodeCollection(inputDirectory)
-		if err != nil {
-			errorExit(1, err.Error())
+		buildOpts := render.BuildOptions{
+			InputDirectory:  inputDirectory,
+			OutputDirectory: outputDirectory,
+			Format:          format,
+			Minify:          enableMinify,
+			Lenient:         opts.BuildCommand.Lenient,
+			Environment:     opts.BuildCommand.Environment,
+			Overrides:       overrides,
+			Drafts:          opts.BuildCommand.Drafts,
+			Future:          opts.BuildCommand.Future,
+			Expired:         opts.BuildCommand.Expired,
+			Logger:          logger,
 		}
 
-		decodeTimeElapsed := time.Since(decodeTimeStart)
+		if progress != nil {
+			buildOpts.Progress = progress.update
+		}
 
-		if !opts.NoNonEssentialOutput {
-			fmt.Printf("Decoded (%v)\n", decodeTimeElapsed)
+		report, err := render.Build(ctx, buildOpts)
+		if progress != nil {
+			progress.clear()
+		}
+
+		if err != nil {
+			errorExit(1, "%v", err)
 		}
 
-		renderTimeStart := time.Now()
+		if !opts.NoNonEssentialOutput && opts.LogFormat == "json" {
+			// Summary for scripts, separate from the log messages in stderr
+			json.NewEncoder(os.Stdout).Encode(map[string]any{
+				"books":    report.Books,
+				"chapters": report.Chapters,
+				"pages":    len(report.Pages),
+				"bytes":    report.Bytes,
+				"warnings": len(report.Warnings),
+				"duration": report.TotalDuration.Seconds(),
+			})
+		} else if !opts.NoNonEssentialOutput {
+			fmt.Printf("Decoded (%v)\n", report.DecodeDuration)
+			fmt.Printf("Generated %v (%v)\n", format, report.RenderDuration)
+			fmt.Printf(terminalPrintBold("Done")+" (%v): %v books, %v chapters, %v pages, %v, %v warnings\n",
+				report.TotalDuration, report.Books, report.Chapters, len(report.Pages), formatBytes(report.Bytes), len(report.Warnings))
+		}
+	} else if command == "validate" {
+		errs, err := bookgen.ValidateCollection(opts.ValidateCommand.InputDirectory, opts.ValidateCommand.Environment)
+		if err != nil {
+			errorExit(1, "%v", err)
+		}
 
-		if err := RenderCollectionToWebsite(&collection, inputDirectory, outputDirectory, enableMinify); err != nil {
-			errorExit(1, err.Error())
+		for _, e := range errs {
+			logger.Error(e.Error())
 		}
 
-		renderTimeElapsed := time.Since(renderTimeStart)
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+
+		if !opts.NoNonEssentialOutput {
+			fmt.Println(terminalPrintBold("Valid") + ": no problems found")
+		}
+	} else if command == "schema" {
+		if err := writeSchemas(&opts.SchemaCommand); err != nil {
+			errorExit(1, "%v", err)
+		}
+	} else if command == "migrate" {
+		migrated, err := bookgen.MigrateCollection(opts.MigrateCommand.InputDirectory, bookgen.MigrateOptions{
+			DryRun: opts.MigrateCommand.DryRun,
+		})
 
 		if !opts.NoNonEssentialOutput {
-			fmt.Printf("Generated website (%v)\n", renderTimeElapsed)
+			for _, file := range migrated {
+				fmt.Println(terminalPrintBold(file.Path))
+				for _, change := range file.Changes {
+					fmt.Printf("    %v\n", change)
+				}
+			}
+		}
+
+		if err != nil {
+			errorExit(1, "%v", err)
 		}
 
-		totalTimeElapsed := time.Since(totalTimeStart)
 		if !opts.NoNonEssentialOutput {
-			fmt.Printf(terminalPrintBold("Done")+" (%v)\n", totalTimeElapsed)
+			switch {
+			case len(migrated) == 0:
+				fmt.Printf("Already at configuration format version %v\n", bookgen.CurrentConfigFormatVersion)
+			case opts.MigrateCommand.DryRun:
+				fmt.Printf("Would migrate %v files (dry run)\n", len(migrated))
+			default:
+				fmt.Printf(terminalPrintBold("Done")+": migrated %v files to configuration format version %v\n", len(migrated), bookgen.CurrentConfigFormatVersion)
+			}
+		}
+	} else if command == "stats" {
+		if err := writeStats(&opts.StatsCommand); err != nil {
+			errorExit(1, "%v", err)
 		}
 	} else {
 		errorExit(1, "unrecognized command. See `%v --help` for more information", os.Args[0])
 	}
 }
 
+// Print the schema of --kind, or write all schemas into
+// --output-directory.
+func writeSchemas(opts *SchemaOpts) error {
+	schemas := map[string]*bookgen.Schema{
+		"collection": bookgen.CollectionSchema(),
+		"book":       bookgen.BookSchema(),
+		"chapter":    bookgen.ChapterSchema(),
+	}
+
+	if opts.OutputDirectory == "" {
+		kind := opts.Kind
+		if kind == "" {
+			kind = "collection"
+		}
+
+		schema, ok := schemas[kind]
+		if !ok {
+			return fmt.Errorf("invalid schema kind `%v`. Must be one of the following options: collection | book | chapter.", kind)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(schema)
+	}
+
+	if err := os.MkdirAll(opts.OutputDirectory, render.DirPerms); err != nil {
+		return fmt.Errorf("failed to create directory `%v`. %w", opts.OutputDirectory, err)
+	}
+
+	for _, schema := range schemas {
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Join(opts.OutputDirectory, schema.ID)
+		if err := os.WriteFile(name, append(data, '\n'), render.FilePerms); err != nil {
+			return fmt.Errorf("failed to write file `%v`. %w", name, err)
+		}
+	}
+
+	return nil
+}
+
 func errorExit(code int, format string, a ...any) {
-	fmt.Fprintf(os.Stderr, terminalPrintBold("bookgen error: ")+format+"\n", a...)
+	logger.Error(fmt.Sprintf(format, a...))
 	os.Exit(code)
 }
 
+// Format a size in bytes for people, e.g. `1.5 MB`.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
 func terminalPrintBold(s string) string {
 	if EnablePlainOutput {
 		return s
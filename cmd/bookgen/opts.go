@@ -86,6 +86,21 @@ func OptsParseHelper(opts any, args []string, returnConsumedArgs bool) (string,
 						consumedArgs = append(consumedArgs, currentArg, args[i+1])
 					}
 					i++
+				case reflect.Slice:
+					if fieldValue.Type().Elem().Kind() != reflect.String {
+						return command, posArgs, fmt.Errorf("arg '%v': unsupported field type %v", currentArg, fieldValue.Type())
+					}
+
+					if (i + 1) >= len(args) {
+						return command, posArgs, fmt.Errorf("arg '%v': missing value argument", currentArg)
+					}
+
+					// Repeatable, each value is appended
+					fieldValue.Set(reflect.Append(fieldValue, reflect.ValueOf(args[i+1])))
+					if returnConsumedArgs {
+						consumedArgs = append(consumedArgs, currentArg, args[i+1])
+					}
+					i++
 				default:
 					return command, posArgs, fmt.Errorf("arg '%v': unsupported field type %v", currentArg, fieldValue.Type())
 				}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/JessebotX/bookgen/render"
+)
+
+// progressLine shows the latest event of a build on a single line of
+// the terminal, which is rewritten for every event.
+type progressLine struct {
+	mu       sync.Mutex
+	f        *os.File
+	shown    bool
+	lastDraw time.Time
+	books    int
+	chapters int
+}
+
+// Returns a progressLine writing into f, or nil if f is not a terminal
+// or plain output is enabled.
+func newProgressLine(f *os.File) *progressLine {
+	if EnablePlainOutput {
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	return &progressLine{f: f}
+}
+
+func (p *progressLine) update(e render.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var text string
+	switch e.Kind {
+	case render.EventBookStarted:
+		p.books++
+		text = fmt.Sprintf("Decoding book %v (%v books, %v chapters)", e.Book, p.books, p.chapters)
+	case render.EventChapterDecoded:
+		p.chapters++
+		text = fmt.Sprintf("Decoding book %v (%v books, %v chapters)", e.Book, p.books, p.chapters)
+	case render.EventPageWritten, render.EventAssetCopied:
+		text = fmt.Sprintf("Writing [%v/%v] %v", e.Done, e.Total, e.Path)
+	default:
+		return
+	}
+
+	// Redrawing for every event slows down large builds, but the last
+	// file is always shown.
+	last := e.Total > 0 && e.Done == e.Total
+	if time.Since(p.lastDraw) < 50*time.Millisecond && !last {
+		return
+	}
+	p.lastDraw = time.Now()
+
+	fmt.Fprint(p.f, "\r\033[K"+text)
+	p.shown = true
+}
+
+// Remove the line so that other messages can be printed.
+func (p *progressLine) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.shown {
+		fmt.Fprint(p.f, "\r\033[K")
+		p.shown = false
+	}
+}
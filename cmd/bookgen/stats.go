@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/JessebotX/bookgen"
+)
+
+type statsReport struct {
+	Stats bookgen.StatsExport `json:"stats"`
+	Books []statsReportBook   `json:"books"`
+}
+
+type statsReportBook struct {
+	PageName string               `json:"pageName"`
+	Title    string               `json:"title"`
+	Stats    bookgen.StatsExport  `json:"stats"`
+	Chapters []statsReportChapter `json:"chapters"`
+}
+
+type statsReportChapter struct {
+	PageName string              `json:"pageName"`
+	Title    string              `json:"title"`
+	Stats    bookgen.StatsExport `json:"stats"`
+}
+
+// Decode the collection in --input-directory and print the statistics
+// of each book and chapter as a table or JSON.
+func writeStats(opts *StatsOpts) error {
+	format := opts.Format
+	if format == "" {
+		format = "table"
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format `%v`. Must be one of the following options: table | json.", format)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c, err := bookgen.DecodeCollectionContext(ctx, opts.InputDirectory, bookgen.DecodeOptions{
+		Logger:      logger,
+		Environment: opts.Environment,
+		Lenient:     opts.Lenient,
+		Overrides:   bookgen.EnvOverrides(os.Environ()),
+		Drafts:      opts.Drafts,
+		Future:      opts.Future,
+		Expired:     opts.Expired,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for _, warning := range c.Warnings {
+		logger.Warn(fmt.Sprintf("collection: %v", warning))
+	}
+
+	for _, b := range c.Books {
+		for _, warning := range b.Warnings {
+			logger.Warn(fmt.Sprintf("book `%v`: %v", b.PageName, warning))
+		}
+	}
+
+	var total bookgen.Stats
+	for _, b := range c.Books {
+		total = total.Add(b.Stats)
+	}
+
+	if format == "json" {
+		report := statsReport{
+			Stats: total.Export(),
+			Books: make([]statsReportBook, 0, len(c.Books)),
+		}
+
+		for _, b := range c.Books {
+			book := statsReportBook{
+				PageName: b.PageName,
+				Title:    b.Title,
+				Stats:    b.Stats.Export(),
+				Chapters: make([]statsReportChapter, 0, len(b.Chapters)),
+			}
+
+			for _, ch := range b.Chapters {
+				book.Chapters = append(book.Chapters, statsReportChapter{
+					PageName: ch.PageName,
+					Title:    ch.Title,
+					Stats:    ch.Stats.Export(),
+				})
+			}
+
+			report.Books = append(report.Books, book)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	writeRow := func(book, chapter string, s bookgen.Stats) {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", book, chapter, s.Words, s.Characters, formatReadingTime(s), s.CodeBlocks, s.Images)
+	}
+
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", "BOOK", "CHAPTER", "WORDS", "CHARACTERS", "READING TIME", "CODE BLOCKS", "IMAGES")
+	for _, b := range c.Books {
+		for _, ch := range b.Chapters {
+			writeRow(b.PageName, ch.PageName, ch.Stats)
+		}
+		writeRow(b.PageName, "(total)", b.Stats)
+	}
+
+	if len(c.Books) > 1 {
+		writeRow("(total)", "", total)
+	}
+
+	return w.Flush()
+}
+
+// Format a reading time for people, e.g. `12 min`.
+func formatReadingTime(s bookgen.Stats) string {
+	minutes := s.ReadingMinutes()
+	if minutes < 60 {
+		return strconv.Itoa(minutes) + " min"
+	}
+
+	return fmt.Sprintf("%v h %v min", minutes/60, minutes%60)
+}
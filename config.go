@@ -8,11 +8,17 @@ import (
 	"slices"
 	"strings"
 	"time"
+
+	"github.com/alecthomas/chroma/v2/styles"
 )
 
 var (
 	// Valid fields for Book.Status (case-insensitive).
 	BookStatusValidValues = []string{"completed", "hiatus", "ongoing", "inactive"}
+
+	// Valid values for Schedule.Days (case-insensitive), in the order
+	// of time.Weekday.
+	ScheduleValidDays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
 )
 
 // Author represents an individual writer or contributor of an original work.
@@ -27,6 +33,127 @@ type Content struct {
 	Raw   string
 	HTML  template.HTML
 	XHTML template.HTML
+
+	// HTML of the content before the `<!--more-->` marker, if any
+	summary template.HTML
+
+	// Number of code blocks in the markdown (see Stats.CodeBlocks)
+	codeBlocks int
+}
+
+// Highlighting represents the settings used for syntax highlighting
+// of fenced code blocks.
+type Highlighting struct {
+	// Name of the chroma style used for highlighting (e.g. `github`).
+	Style string
+
+	// Name of the chroma style used when the reader prefers a dark
+	// color scheme. Only used when Classes is true.
+	DarkStyle string
+
+	LineNumbers   bool
+	TabWidth      int
+	GuessLanguage bool
+
+	// Classes outputs CSS classes instead of inline styles, which
+	// requires the stylesheet from Highlighting.Stylesheet.
+	Classes bool
+}
+
+func (h *Highlighting) InitializeDefaults() {
+	h.Style = "github"
+	h.LineNumbers = true
+	h.TabWidth = 8
+}
+
+// CheckRequirementsForParsing checks if the highlighting styles exist
+// and other fields have valid values.
+func (h *Highlighting) CheckRequirementsForParsing() error {
+	for field, style := range map[string]string{"style": h.Style, "darkStyle": h.DarkStyle} {
+		if style == "" && field == "darkStyle" {
+			continue
+		}
+
+		if _, ok := styles.Registry[style]; !ok {
+			return fmt.Errorf("invalid value `%v` for field `highlighting.%v`. Must be one of the following options: %v.", style, field, strings.Join(styles.Names(), " | "))
+		}
+	}
+
+	if h.TabWidth < 1 {
+		return fmt.Errorf("invalid value for field `highlighting.tabWidth`. Must be greater than 0.")
+	}
+
+	return nil
+}
+
+// Images represents the settings used for generating resized variants
+// of the cover image and images in markdown content.
+type Images struct {
+	// Widths (in pixels) of the resized variants listed in `srcset`.
+	// Widths that are not smaller than an image are skipped.
+	Widths []int
+
+	// Width (in pixels) of the cover image thumbnail.
+	ThumbnailWidth int
+
+	// Quality of JPEG variants, from 1 to 100.
+	Quality int
+}
+
+func (i *Images) InitializeDefaults() {
+	i.Widths = []int{480, 960, 1600}
+	i.ThumbnailWidth = 320
+	i.Quality = 80
+}
+
+// CheckRequirementsForParsing checks if the image sizes and quality
+// have valid values.
+func (i *Images) CheckRequirementsForParsing() error {
+	for _, width := range i.Widths {
+		if width < 1 {
+			return fmt.Errorf("invalid value `%v` in field `images.widths`. Must be greater than 0.", width)
+		}
+	}
+
+	if i.ThumbnailWidth < 1 {
+		return fmt.Errorf("invalid value for field `images.thumbnailWidth`. Must be greater than 0.")
+	}
+
+	if i.Quality < 1 || i.Quality > 100 {
+		return fmt.Errorf("invalid value for field `images.quality`. Must be between 1 and 100.")
+	}
+
+	return nil
+}
+
+// ReadingTime represents the settings used for estimating the reading
+// time of chapters and books (see Stats).
+type ReadingTime struct {
+	// Words read per minute.
+	WordsPerMinute int
+
+	// Characters read per minute in Chinese, Japanese and Korean (see
+	// Chapter.LanguageCode), which are counted by character.
+	CJKCharactersPerMinute int
+}
+
+func (r *ReadingTime) InitializeDefaults() {
+	r.WordsPerMinute = 200
+	r.CJKCharactersPerMinute = 500
+}
+
+// CheckRequirementsForParsing checks if the reading speeds have valid
+// values.
+func (r *ReadingTime) CheckRequirementsForParsing() error {
+	if r.WordsPerMinute < 1 {
+		return fmt.Errorf("invalid value for field `readingTime.wordsPerMinute`. Must be greater than 0.")
+	}
+
+	if r.CJKCharactersPerMinute < 1 {
+		return fmt.Errorf("invalid value for field `readingTime.cjkCharactersPerMinute`. Must be greater than 0.")
+	}
+
+	return nil
 }
 
 // Internal represents the app's settings that may be useful
@@ -36,6 +163,28 @@ type Internal struct {
 	LayoutsDirectory string
 }
 
+// Schedule represents the regular release schedule of a serialized
+// book, e.g. every Friday at 18:00 in America/Toronto.
+type Schedule struct {
+	// Days of the week on which chapters are released (e.g. `friday`,
+	// case-insensitive). The schedule is unused if it is empty.
+	Days []string
+
+	// Time of day of a release as `15:04`. Defaults to midnight.
+	Time string
+
+	// IANA name of the time zone of Time (e.g. `America/Toronto`).
+	// Defaults to UTC.
+	TimeZone string
+}
+
+// CheckRequirementsForParsing checks if the days, time and time zone
+// of the schedule are valid.
+func (s *Schedule) CheckRequirementsForParsing() error {
+	_, err := s.parse()
+	return err
+}
+
 // Series represent a set of books that are related to each other,
 // such as sequels, prequels, side stories, etc.
 type Series struct {
@@ -56,13 +205,27 @@ type SocialLink struct {
 type Collection struct {
 	Params              map[string]any
 	Internal            Internal
+	Highlighting        Highlighting
+	Images              Images
+	ReadingTime         ReadingTime
 	Title               string
 	Description         string
 	BaseURL             string
 	LanguageCode        string
 	Books               []Book
+	Taxonomies          []Taxonomy
 	FaviconImageName    string
 	ConfigFormatVersion int
+
+	// Number of words of automatic summaries of chapters and books
+	// without a `<!--more-->` marker (see Summary).
+	SummaryLength int
+
+	// Non-fatal problems found while decoding the collection, such as
+	// unknown keys or taxonomy terms with the same slug.
+	Warnings []string
+
+	markdown *markdownConverter
 }
 
 func (c *Collection) InitializeDefaults() {
@@ -70,6 +233,10 @@ func (c *Collection) InitializeDefaults() {
 	c.ConfigFormatVersion = 0
 	c.Internal.GenerateEPUB = true
 	c.Internal.LayoutsDirectory = "layouts"
+	c.Highlighting.InitializeDefaults()
+	c.Images.InitializeDefaults()
+	c.ReadingTime.InitializeDefaults()
+	c.SummaryLength = 70
 }
 
 // Close properly deallocates elements in the Collection object such
@@ -92,6 +259,22 @@ func (c *Collection) CheckRequirementsForParsing() error {
 		return fmt.Errorf("missing/empty required field `title`")
 	}
 
+	if err := c.Highlighting.CheckRequirementsForParsing(); err != nil {
+		return err
+	}
+
+	if err := c.Images.CheckRequirementsForParsing(); err != nil {
+		return err
+	}
+
+	if err := c.ReadingTime.CheckRequirementsForParsing(); err != nil {
+		return err
+	}
+
+	if c.SummaryLength < 1 {
+		return fmt.Errorf("invalid value for field `summaryLength`. Must be greater than 0.")
+	}
+
 	return nil
 }
 
@@ -101,7 +284,7 @@ func (c *Collection) CheckRequirementsForParsing() error {
 // within the Collection in Collection.Books.
 type Book struct {
 	Params           map[string]any
-	Parent           *Collection
+	Parent           *Collection `json:"-"`
 	Internal         Internal
 	PageName         string
 	BaseURL          string
@@ -116,15 +299,51 @@ type Book struct {
 	IDs              []string
 	Tags             []string
 	CoverImageName   string
+	CoverImage       *Image
 	FaviconImageName string
 	Status           string
+	Schedule         Schedule
 	LanguageCode     string
 	Mirrors          []SocialLink
 	DatePublished    time.Time
 	DateModified     time.Time
+	DateExpires      time.Time
+	Draft            bool
 	Content          Content
+	Summary          Summary
 	IsStub           bool
 	Chapters         []Chapter
+
+	// Sum of the statistics of Chapters.
+	Stats Stats
+
+	// Chapters that are published after the time of the build, which
+	// are not in Chapters unless scheduled chapters are included (see
+	// DecodeOptions.Future), sorted by date.
+	Releases []Release
+
+	// Date on which the next chapter is expected: the date of the
+	// first of Releases, or the next date of Schedule if the book is
+	// ongoing. Zero if it is not known.
+	NextRelease time.Time
+
+	// Files copied into the book's output directory, relative to it,
+	// such as the cover image, files in the `assets` directory and
+	// chapter bundles, and files referenced in markdown content.
+	Assets []string
+
+	// Non-fatal problems found while decoding the book, such as a
+	// cover image with an unusual aspect ratio.
+	Warnings []string
+
+	images *imageSet
+	assets *assetSet
+
+	// Files referenced in the content of the book
+	contentAssets []string
+
+	// Page names of chapters linked in the content of the book
+	contentChapters []string
 }
 
 func (b *Book) InitializeDefaults(workingDir string, parent *Collection) {
@@ -136,7 +355,12 @@ func (b *Book) InitializeDefaults(workingDir string, parent *Collection) {
 	b.Internal.LayoutsDirectory = "layouts"
 	b.LanguageCode = "en"
 
+	var images Images
+	images.InitializeDefaults()
+
 	if parent != nil {
+		images = parent.Images
+
 		b.BaseURL, _ = url.JoinPath(parent.BaseURL, "books", b.PageName)
 
 		b.Internal.GenerateEPUB = parent.Internal.GenerateEPUB
@@ -145,6 +369,9 @@ func (b *Book) InitializeDefaults(workingDir string, parent *Collection) {
 			b.LanguageCode = parent.LanguageCode
 		}
 	}
+
+	b.images = newImageSet(osSource(workingDir), ".", images)
+	b.assets = newAssetSet(osSource(workingDir), ".")
 }
 
 // CheckRequirementsForParsing checks if required fields have valid
@@ -169,6 +396,10 @@ func (b *Book) CheckRequirementsForParsing(workingDir string) error {
 		}
 	}
 
+	if err := b.Schedule.CheckRequirementsForParsing(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -188,9 +419,9 @@ func (b *Book) Close() {
 // NOTE: Chapter.PageName must be unique within a Book in Book.Chapters
 type Chapter struct {
 	Params        map[string]any
-	Parent        *Book
-	Previous      *Chapter
-	Next          *Chapter
+	Parent        *Book    `json:"-"`
+	Previous      *Chapter `json:"-"`
+	Next          *Chapter `json:"-"`
 	PageName      string
 	Title         string
 	Subtitle      string
@@ -201,12 +432,34 @@ type Chapter struct {
 	LanguageCode  string
 	DatePublished time.Time
 	DateModified  time.Time
+	DateExpires   time.Time
+	Draft         bool
 	Content       Content
+	Summary       Summary
+	Stats         Stats
+
+	// Files referenced in the chapter's content, relative to the book's
+	// output directory.
+	Assets []string
+
+	// Non-fatal problems found while decoding the chapter, which are
+	// also added to the Warnings of its book.
+	Warnings []string
+
+	// Page names of chapters linked in the chapter's content
+	linkedChapters []string
+
+	// Whether DatePublished is projected from the book's schedule
+	projected bool
 }
 
 func (c *Chapter) InitializeDefaults(workingDir string, parent *Book) {
 	c.Parent = parent
 	c.PageName = strings.TrimSuffix(filepath.Base(workingDir), ".md")
+	if filepath.Base(workingDir) == "index.md" {
+		// Chapter bundle (chapters/NAME/index.md)
+		c.PageName = filepath.Base(filepath.Dir(workingDir))
+	}
 	c.Order = 1
 
 	if parent != nil {
@@ -0,0 +1,188 @@
+package bookgen
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io/fs"
+	"math"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Valid formats for Book.CoverImageName.
+	CoverImageValidFormats = []string{"jpeg", "png", "gif", "webp"}
+
+	// Width and height (in pixels) below which a cover image is
+	// warned about.
+	CoverImageMinimumSize = 300
+)
+
+const (
+	generatedCoverName   = "cover.svg"
+	generatedCoverWidth  = 1600
+	generatedCoverHeight = 2400
+)
+
+// Read and check the cover image of a book, or generate one if
+// Book.CoverImageName is not set.
+func (b *Book) readCoverImage() error {
+	if strings.TrimSpace(b.CoverImageName) == "" {
+		b.CoverImage = &Image{
+			Name:   generatedCoverName,
+			Width:  generatedCoverWidth,
+			Height: generatedCoverHeight,
+			format: "svg",
+			data:   generateCover(b),
+		}
+		b.CoverImage.Thumbnail = &ImageVariant{Name: generatedCoverName, Width: generatedCoverWidth, Height: generatedCoverHeight}
+		b.CoverImageName = generatedCoverName
+		b.assets.add(generatedCoverName)
+
+		return nil
+	}
+
+	coverName := path.Join(b.images.dir, filepath.ToSlash(b.CoverImageName))
+	coverPath := b.images.src.path(coverName)
+	if _, err := fs.Stat(b.images.src.fsys, coverName); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("cover image `%v` does not exist", coverPath)
+		}
+		return err
+	}
+
+	cover, err := b.images.get(b.CoverImageName)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(CoverImageValidFormats, cover.format) {
+		return fmt.Errorf("invalid format `%v` for cover image `%v`. Must be one of the following formats: %v.", cover.format, coverPath, strings.Join(CoverImageValidFormats, " | "))
+	}
+
+	if cover.Width < CoverImageMinimumSize || cover.Height < CoverImageMinimumSize {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("cover image `%v` is small (%vx%v). Covers should be at least %vx%v pixels.", coverPath, cover.Width, cover.Height, CoverImageMinimumSize, CoverImageMinimumSize))
+	}
+
+	// Most covers are around 1:1.6 (e.g. 1600x2560)
+	if ratio := float64(cover.Height) / float64(cover.Width); ratio < 1.25 || ratio > 1.8 {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("cover image `%v` has an unusual aspect ratio (%vx%v, 1:%.2f). Most covers are between 1:1.25 and 1:1.8.", coverPath, cover.Width, cover.Height, ratio))
+	}
+
+	thumbnail := cover.variant(b.images.settings.ThumbnailWidth)
+	cover.Thumbnail = &thumbnail
+
+	b.CoverImage = cover
+	b.assets.add(b.CoverImageName)
+
+	return nil
+}
+
+// Generate a simple typographic SVG cover from the title, subtitle,
+// series and authors of a book. The background color is picked from
+// the title so that books in a collection look different.
+func generateCover(b *Book) []byte {
+	hash := fnv.New32a()
+	hash.Write([]byte(b.Title))
+	background := hslToHex(float64(hash.Sum32()%360), 0.35, 0.28)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Georgia, serif" fill="#f5f1e8" text-anchor="middle">`,
+		generatedCoverWidth, generatedCoverHeight, generatedCoverWidth, generatedCoverHeight)
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="%s"/>`, background)
+	fmt.Fprintf(&sb, `<rect x="80" y="80" width="%d" height="%d" fill="none" stroke="#f5f1e8" stroke-width="6"/>`, generatedCoverWidth-160, generatedCoverHeight-160)
+
+	x := generatedCoverWidth / 2
+	y := 720
+	for _, line := range wrapText(b.Title, 14) {
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="150" font-weight="bold">%s</text>`, x, y, html.EscapeString(line))
+		y += 180
+	}
+
+	if b.Subtitle != "" {
+		y += 20
+		for _, line := range wrapText(b.Subtitle, 26) {
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="80" font-style="italic">%s</text>`, x, y, html.EscapeString(line))
+			y += 100
+		}
+	}
+
+	if b.Series.Name != "" {
+		series := b.Series.Name
+		if b.Series.Number != 0 {
+			series += ", Book " + strconv.FormatFloat(float64(b.Series.Number), 'f', -1, 32)
+		}
+
+		y += 40
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="64" letter-spacing="4">%s</text>`, x, y, html.EscapeString(strings.ToUpper(series)))
+	}
+
+	names := make([]string, 0, len(b.Authors))
+	for _, author := range b.Authors {
+		names = append(names, author.Name)
+	}
+
+	lines := wrapText(strings.Join(names, ", "), 28)
+	y = generatedCoverHeight - 200 - 100*(len(lines)-1)
+	for _, line := range lines {
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="84">%s</text>`, x, y, html.EscapeString(line))
+		y += 100
+	}
+
+	sb.WriteString(`</svg>`)
+	return []byte(sb.String())
+}
+
+// Split s into lines of about width characters, breaking at spaces.
+func wrapText(s string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// Convert an HSL color (hue in degrees, saturation and lightness from
+// 0 to 1) into a hex RGB color.
+func hslToHex(h, s, l float64) string {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round((r+m)*255)), int(math.Round((g+m)*255)), int(math.Round((b+m)*255)))
+}
@@ -3,89 +3,334 @@ package bookgen
 import (
 	"bytes"
 	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
-	"os"
+	"io/fs"
+	"log/slog"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
-
-	"github.com/go-viper/mapstructure/v2"
-
-	"github.com/goccy/go-yaml"
-
+	"github.com/JessebotX/bookgen/internal/admonition"
+	"github.com/JessebotX/bookgen/internal/diagram"
 	"github.com/JessebotX/bookgen/internal/highlighting"
+	"github.com/JessebotX/bookgen/internal/imagesize"
+	"github.com/JessebotX/bookgen/internal/links"
+	"github.com/JessebotX/bookgen/internal/mathml"
 	"github.com/JessebotX/bookgen/internal/meta"
+	"github.com/JessebotX/bookgen/internal/shortcode"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 
 	"golang.org/x/sync/errgroup"
 )
 
 var (
-	goldmarkExtensions = goldmark.WithExtensions(
-		highlighting.NewHighlighting(
-			highlighting.WithFormatOptions(
-				chromahtml.WithLineNumbers(true),
-			),
-		),
-		meta.Meta,
-		extension.GFM,
-		extension.Footnote,
-		extension.Typographer,
-	)
-	markdownToHTML = goldmark.New(
-		goldmarkExtensions,
-		goldmark.WithParserOptions(
-			parser.WithAttribute(),
-			parser.WithAutoHeadingID(),
-		),
-		goldmark.WithRendererOptions(),
+	defaultMarkdownConverter = newMarkdownConverter(defaultHighlighting(), nil)
+)
+
+// markdownConverter converts markdown into HTML and XHTML using the
+// settings of a Collection.
+type markdownConverter struct {
+	html       goldmark.Markdown
+	xhtml      goldmark.Markdown
+	shortcodes *template.Template
+}
+
+func newMarkdownConverter(h Highlighting, shortcodes *template.Template) *markdownConverter {
+	extensions := func(useXHTML bool) goldmark.Option {
+		return goldmark.WithExtensions(
+			highlighting.NewHighlighting(append(
+				h.options(h.Style),
+				highlighting.WithWrapperRenderer(codeBlockWrapper(useXHTML)),
+			)...),
+			meta.Meta,
+			shortcode.Shortcodes,
+			admonition.Admonitions,
+			mathml.MathML,
+			diagram.Diagrams,
+			links.Links,
+			imagesize.ImageSize,
+			extension.GFM,
+			extension.Footnote,
+			extension.Typographer,
+		)
+	}
+
+	parserOptions := goldmark.WithParserOptions(
+		parser.WithAttribute(),
+		parser.WithAutoHeadingID(),
 	)
-	markdownToXHTML = goldmark.New(
-		goldmarkExtensions,
-		goldmark.WithParserOptions(
-			parser.WithAttribute(),
-			parser.WithAutoHeadingID(),
+
+	return &markdownConverter{
+		html: goldmark.New(
+			extensions(false),
+			parserOptions,
+			goldmark.WithRendererOptions(),
 		),
-		goldmark.WithRendererOptions(
-			html.WithXHTML(),
+		xhtml: goldmark.New(
+			extensions(true),
+			parserOptions,
+			goldmark.WithRendererOptions(
+				html.WithXHTML(),
+			),
 		),
-	)
+		shortcodes: shortcodes,
+	}
+}
+
+func defaultHighlighting() Highlighting {
+	var h Highlighting
+	h.InitializeDefaults()
+	return h
+}
+
+// DecodeEventKind is the kind of a DecodeEvent.
+type DecodeEventKind string
+
+const (
+	DecodeEventBookStarted    DecodeEventKind = "book-started"
+	DecodeEventChapterDecoded DecodeEventKind = "chapter-decoded"
 )
 
+// DecodeEvent describes a step of decoding a collection.
+type DecodeEvent struct {
+	Kind DecodeEventKind
+
+	// Page names of the book, and of the chapter for
+	// DecodeEventChapterDecoded.
+	Book    string
+	Chapter string
+}
+
+// DecodeOptions represents the settings of decoding a collection.
+type DecodeOptions struct {
+	// Progress is called when a book is started and when a chapter is
+	// decoded. It may be called from multiple goroutines.
+	Progress func(DecodeEvent)
+
+	// Logger for messages about decoding. Defaults to discarding all
+	// messages. Warnings are not logged, but added to the Warnings of
+	// the Collection and its books.
+	Logger *slog.Logger
+
+	// Lenient reports unknown keys in configuration files and front
+	// matter as warnings instead of errors, and converts values
+	// between types where possible (e.g. "1" into 1). See ConfigError.
+	Lenient bool
+
+	// Environment (e.g. `production`) whose configuration files, such
+	// as bookgen.production.yml and bookgen-book.production.yml, are
+	// merged over bookgen.yml and bookgen-book.yml. Files of the
+	// environment are optional.
+	Environment string
+
+	// Overrides of keys in the collection's configuration, set in
+	// order after merging the configuration files.
+	Overrides []Override
+
+	// Include books and chapters that are drafts (`draft: true`),
+	// scheduled (published after Now) or expired (`expires` before
+	// Now), which are left out of the Collection by default. Chapters
+	// with a date projected from the schedule are also scheduled, with
+	// a warning.
+	Drafts  bool
+	Future  bool
+	Expired bool
+
+	// Time used to decide which books and chapters are scheduled or
+	// expired. Defaults to the time of decoding.
+	Now time.Time
+}
+
+// decoder holds the state of a single decode.
+type decoder struct {
+	ctx    context.Context
+	opts   DecodeOptions
+	logger *slog.Logger
+}
+
+func newDecoder(ctx context.Context, opts DecodeOptions) *decoder {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	return &decoder{
+		ctx:    ctx,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Returns why a book or chapter is left out of the Collection, or an
+// empty string if it is included.
+func (d *decoder) excluded(draft bool, published, expires time.Time) string {
+	switch {
+	case draft && !d.opts.Drafts:
+		return "draft"
+	case !published.IsZero() && published.After(d.opts.Now) && !d.opts.Future:
+		return "scheduled"
+	case !expires.IsZero() && !expires.After(d.opts.Now) && !d.opts.Expired:
+		return "expired"
+	}
+
+	return ""
+}
+
+// Flags of `bookgen build` that include the chapters excluded for a
+// reason returned by decoder.excluded.
+var excludedFlags = map[string]string{
+	"draft":     "--drafts",
+	"scheduled": "--future",
+	"expired":   "--expired",
+}
+
+// Returns the warning of a link to a chapter that is excluded for
+// reason.
+func excludedLinkWarning(pageName, reason string) string {
+	return fmt.Sprintf("link to chapter `%v` is broken, as the chapter is excluded (%v). Include it with `%v`.", pageName, reason, excludedFlags[reason])
+}
+
+// Returns a decoder for functions that do not take a context.
+func backgroundDecoder() *decoder {
+	return newDecoder(context.Background(), DecodeOptions{})
+}
+
+// Read the configuration file named base (e.g. `bookgen`) in dir,
+// merged with the file of the environment and the overrides, into
+// params.
+func (d *decoder) readConfig(src source, dir, base string, overrides []Override) (map[string]any, *configDecoder, error) {
+	config := &configDecoder{
+		overrides: overrides,
+		strict:    !d.opts.Lenient,
+	}
+
+	bases := []string{base}
+	if d.opts.Environment != "" {
+		bases = append(bases, base+"."+d.opts.Environment)
+	}
+
+	params := make(map[string]any)
+	for i, base := range bases {
+		name, format, err := findConfigFile(src, dir, base)
+		if i > 0 && errors.Is(err, fs.ErrNotExist) {
+			d.logger.Debug("no configuration file for environment", "environment", d.opts.Environment, "path", src.path(dir), "name", base)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		pathConfig := src.path(name)
+		data, err := fs.ReadFile(src.fsys, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read file `%v`. %w", pathConfig, err)
+		}
+
+		var fileParams map[string]any
+		if err := meta.Unmarshal(format, data, &fileParams); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %v in `%v`. %w", strings.ToUpper(string(format)), pathConfig, err)
+		}
+		mergeParams(params, fileParams)
+
+		config.files = append(config.files, configFile{
+			path:   pathConfig,
+			format: format,
+			data:   data,
+		})
+	}
+
+	if len(overrides) > 0 {
+		schema := CollectionSchema()
+		for _, override := range overrides {
+			override.apply(params, schema)
+		}
+	}
+
+	return params, config, nil
+}
+
+func (d *decoder) progress(e DecodeEvent) {
+	if d.opts.Progress != nil {
+		d.opts.Progress(e)
+	}
+}
+
 // Decode a structured directory with a bookgen configuration file
 // into a Collection.
 func DecodeCollection(workingDir string) (Collection, error) {
+	return backgroundDecoder().decodeCollection(osSource(workingDir))
+}
+
+// DecodeCollectionContext is like DecodeCollection, but stops when ctx
+// is canceled and reports progress to DecodeOptions.Progress.
+func DecodeCollectionContext(ctx context.Context, workingDir string, opts DecodeOptions) (Collection, error) {
+	return newDecoder(ctx, opts).decodeCollection(osSource(workingDir))
+}
+
+// DecodeCollectionFS is like DecodeCollection, but reads the
+// structured directory from the root of fsys (e.g. an embedded
+// filesystem or a zip archive) instead of the OS filesystem.
+func DecodeCollectionFS(fsys fs.FS) (Collection, error) {
+	return backgroundDecoder().decodeCollection(source{fsys: fsys})
+}
+
+// DecodeCollectionFSContext is like DecodeCollectionFS, but stops when
+// ctx is canceled and reports progress to DecodeOptions.Progress.
+func DecodeCollectionFSContext(ctx context.Context, fsys fs.FS, opts DecodeOptions) (Collection, error) {
+	return newDecoder(ctx, opts).decodeCollection(source{fsys: fsys})
+}
+
+func (d *decoder) decodeCollection(src source) (Collection, error) {
 	// ---
-	// Read file
+	// Read config files
 	// ---
-	pathConfig := filepath.Join(workingDir, "bookgen.yml")
-	dataConfig, err := os.ReadFile(pathConfig)
+	var c Collection
+	c.InitializeDefaults()
+
+	params, config, err := d.readConfig(src, ".", "bookgen", d.opts.Overrides)
 	if err != nil {
-		return Collection{}, fmt.Errorf("collection: failed to read file `%v`. %w", pathConfig, err)
+		return c, fmt.Errorf("collection: %w", err)
 	}
+	c.Params = params
 
 	// ---
-	// Decode config
+	// Migrate config
 	// ---
-	var c Collection
-	c.InitializeDefaults()
+	version, err := configVersion(params)
+	if err != nil {
+		return c, fmt.Errorf("collection: %w", err)
+	}
 
-	if err := yaml.Unmarshal(dataConfig, &c.Params); err != nil {
-		return c, fmt.Errorf("collection: failed to decode YAML in `%v`. %w", pathConfig, err)
+	if version < latestConfigVersion {
+		migrateParams(configKindCollection, version, params)
+		c.Warnings = append(c.Warnings, fmt.Sprintf("configuration format version %v is older than the current version %v. Run `bookgen migrate` to update the files.", version, latestConfigVersion))
 	}
 
-	if err := mapstructure.Decode(c.Params, &c); err != nil {
-		return c, fmt.Errorf("collection: failed to decode YAML in `%v`. %w", pathConfig, err)
+	// ---
+	// Decode config
+	// ---
+	warnings, err := config.decode(c.Params, &c)
+	if err != nil {
+		return c, fmt.Errorf("collection: failed to decode configuration.\n%w", err)
 	}
+	c.Warnings = append(c.Warnings, warnings...)
+
+	d.logger.Debug("decoded collection config", "path", config.files[0].path, "files", len(config.files), "overrides", len(config.overrides))
 
 	// ---
 	// Check requirements
@@ -94,19 +339,29 @@ func DecodeCollection(workingDir string) (Collection, error) {
 		return c, fmt.Errorf("collection: failed to meet requirements. %w", err)
 	}
 
+	// ---
+	// Read shortcodes
+	// ---
+	shortcodesDir := path.Join(filepath.ToSlash(c.Internal.LayoutsDirectory), "shortcodes")
+	shortcodes, err := shortcode.ParseFS(src.fsys, shortcodesDir)
+	if err != nil {
+		return c, fmt.Errorf("collection: failed to read shortcodes directory `%v`. %w", src.path(shortcodesDir), err)
+	}
+
+	c.markdown = newMarkdownConverter(c.Highlighting, shortcodes)
+
 	// ---
 	// Decode books
 	// ---
 	c.Books = make([]Book, 0)
-	booksDir := filepath.Join(workingDir, "books")
-	items, err := os.ReadDir(booksDir)
+	items, err := fs.ReadDir(src.fsys, "books")
 	if err != nil {
-		if os.IsNotExist(err) { // no error, do nothing
+		if errors.Is(err, fs.ErrNotExist) { // no error, do nothing
 			return c, nil
 		}
 
 		// error
-		return c, fmt.Errorf("collection: failed to read books directory %v. %w", booksDir, err)
+		return c, fmt.Errorf("collection: failed to read books directory %v. %w", src.path("books"), err)
 	}
 
 	for _, item := range items {
@@ -114,43 +369,70 @@ func DecodeCollection(workingDir string) (Collection, error) {
 			continue
 		}
 
-		bookWorkingDir := filepath.Join(booksDir, item.Name())
-		book, err := DecodeBook(bookWorkingDir, &c)
+		start := time.Now()
+		book, err := d.decodeBook(src, path.Join("books", item.Name()), &c)
 		if err != nil {
 			return c, err
 		}
 
+		if reason := d.excluded(book.Draft, book.DatePublished, book.DateExpires); reason != "" {
+			d.logger.Info("excluded book", "book", book.PageName, "reason", reason)
+			continue
+		}
+
+		d.logger.Info("decoded book", "book", book.PageName, "chapters", len(book.Chapters), "duration", time.Since(start))
+
 		c.Books = append(c.Books, book)
 	}
 
+	c.buildTaxonomies()
+
 	return c, nil
 }
 
 // Decode a structured directory with a bookgen-book configuration
 // file into a Book.
 func DecodeBook(workingDir string, parent *Collection) (Book, error) {
-	// ---
-	// Read file
-	// ---
-	pathConfig := filepath.Join(workingDir, "bookgen-book.yml")
-	dataConfig, err := os.ReadFile(pathConfig)
-	if err != nil {
-		return Book{}, fmt.Errorf("book: failed to read file `%v`. %w", err)
+	return backgroundDecoder().decodeBook(osSource(workingDir), ".", parent)
+}
+
+// DecodeBookFS is like DecodeBook, but reads the structured directory
+// from dir of fsys instead of the OS filesystem. The base name of dir
+// is used as the page name of the book.
+func DecodeBookFS(fsys fs.FS, dir string, parent *Collection) (Book, error) {
+	return backgroundDecoder().decodeBook(source{fsys: fsys}, dir, parent)
+}
+
+func (d *decoder) decodeBook(src source, dir string, parent *Collection) (Book, error) {
+	if err := d.ctx.Err(); err != nil {
+		return Book{}, err
 	}
 
+	workingDir := src.path(dir)
+
 	// ---
-	// Decode config
+	// Read config files
 	// ---
 	var b Book
 	b.InitializeDefaults(workingDir, parent)
+	b.setSource(src, dir)
+	d.progress(DecodeEvent{Kind: DecodeEventBookStarted, Book: b.PageName})
 
-	if err := yaml.Unmarshal(dataConfig, &b.Params); err != nil {
-		return b, fmt.Errorf("book `%v`: failed to decode YAML in `%v`. %w", b.PageName, pathConfig, err)
+	params, config, err := d.readConfig(src, dir, "bookgen-book", nil)
+	if err != nil {
+		return b, fmt.Errorf("book `%v`: %w", b.PageName, err)
 	}
+	b.Params = params
+	migrateParams(configKindBook, b.configVersion(), params)
 
-	if err := mapstructure.Decode(b.Params, &b); err != nil {
-		return b, fmt.Errorf("book `%v`: failed to decode YAML in `%v`. %w", b.PageName, pathConfig, err)
+	// ---
+	// Decode config
+	// ---
+	warnings, err := config.decode(b.Params, &b)
+	if err != nil {
+		return b, fmt.Errorf("book `%v`: failed to decode configuration.\n%w", b.PageName, err)
 	}
+	b.Warnings = append(b.Warnings, warnings...)
 
 	// ---
 	// Check requirements
@@ -162,18 +444,17 @@ func DecodeBook(workingDir string, parent *Collection) (Book, error) {
 	// ---
 	// Parse markdown
 	// ---
-	rawMarkdownPath := filepath.Join(workingDir, "index.md")
-	rawMarkdown, err := os.ReadFile(rawMarkdownPath)
-	if err != nil && os.IsExist(err) {
+	rawMarkdownPath := src.path(path.Join(dir, "index.md"))
+	rawMarkdown, err := fs.ReadFile(src.fsys, path.Join(dir, "index.md"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return b, fmt.Errorf("book `%v`: failed to read book content file at `%v`, %w", b.PageName, rawMarkdownPath, err)
 	}
-	b.Content.Raw = string(rawMarkdown)
 
-	contentHTML, _, err := convertMarkdownToHTML(rawMarkdown, false)
+	b.Content, _, err = b.markdownConverter().convert(rawMarkdown, b.assets.rewriter(dir, &b.contentAssets, &b.contentChapters), b.images.resolve)
 	if err != nil {
-		return b, fmt.Errorf("book `%v`: failed to convert markdown to HTML. %w", b.PageName, err)
+		return b, fmt.Errorf("book `%v`: failed to convert markdown to HTML in `%v`. %w", b.PageName, rawMarkdownPath, err)
 	}
-	b.Content.HTML = contentHTML
+	b.Summary = newSummary(b.Content, b.summaryLength())
 
 	datePubParam, ok := b.Params["published"]
 	if ok && b.DatePublished.IsZero() {
@@ -191,38 +472,77 @@ func DecodeBook(workingDir string, parent *Collection) (Book, error) {
 		}
 	}
 
+	dateExpParam, ok := b.Params["expires"]
+	if ok && b.DateExpires.IsZero() {
+		b.DateExpires, err = getTimeFromParam(dateExpParam)
+		if err != nil {
+			return b, fmt.Errorf("book `%v`: failed to parse date expires: %w", b.PageName, err)
+		}
+	}
+
 	// ---
-	// TODO: Check existence of files like cover image
+	// Read cover image
+	// TODO: Check existence of other files like favicon
 	// ---
+	if err := b.readCoverImage(); err != nil {
+		return b, fmt.Errorf("book `%v`: failed to read cover image. %w", b.PageName, err)
+	}
+
+	// ---
+	// Read assets
+	// ---
+	if err := b.assets.addDir("assets"); err != nil {
+		return b, fmt.Errorf("book `%v`: failed to read assets directory. %w", b.PageName, err)
+	}
 
 	// ---
 	// Read chapters
 	// ---
-	chaptersDir := filepath.Join(workingDir, "chapters")
-	items, err := os.ReadDir(chaptersDir)
+	chaptersDir := path.Join(dir, "chapters")
+	items, err := fs.ReadDir(src.fsys, chaptersDir)
 	if err != nil {
-		if os.IsExist(err) {
-			return b, fmt.Errorf("book `%v`: failed to read chapters directory at `%v`. %w", b.PageName, chaptersDir, err)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return b, fmt.Errorf("book `%v`: failed to read chapters directory at `%v`. %w", b.PageName, src.path(chaptersDir), err)
 		}
 	}
 
 	// Here chapters can be appended out-of-order because it will
 	// be sorted manually later.
+	var mu sync.Mutex
+	bundles := make(map[string]string) // by page name
 	g := new(errgroup.Group)
 	b.Chapters = make([]Chapter, 0)
 	for _, item := range items {
-		if item.IsDir() || !strings.HasSuffix(item.Name(), ".md") {
+		chapterSourcePath := path.Join(chaptersDir, item.Name())
+
+		if item.IsDir() {
+			// Chapter bundle: a directory with an index.md and the
+			// files used by the chapter.
+			chapterSourcePath = path.Join(chaptersDir, item.Name(), "index.md")
+			if _, err := fs.Stat(src.fsys, chapterSourcePath); err != nil {
+				continue
+			}
+		} else if !strings.HasSuffix(item.Name(), ".md") {
 			continue
 		}
 
-		var c Chapter
-		chapterSourcePath := filepath.Join(chaptersDir, item.Name())
 		g.Go(func() error {
-			c, err = DecodeChapter(chapterSourcePath, &b)
+			start := time.Now()
+			c, err := d.decodeChapter(src, chapterSourcePath, &b)
 			if err != nil {
 				return err
 			}
+
+			d.logger.Debug("decoded chapter", "book", b.PageName, "chapter", c.PageName, "path", src.path(chapterSourcePath), "duration", time.Since(start))
+
+			mu.Lock()
 			b.Chapters = append(b.Chapters, c)
+			if item.IsDir() {
+				bundles[c.PageName] = path.Join("chapters", item.Name())
+			}
+			mu.Unlock()
+
+			d.progress(DecodeEvent{Kind: DecodeEventChapterDecoded, Book: b.PageName, Chapter: c.PageName})
 			return nil
 		})
 	}
@@ -242,6 +562,61 @@ func DecodeBook(workingDir string, parent *Collection) (Book, error) {
 		return strings.Compare(x.Title, y.Title)
 	})
 
+	if err := b.projectChapterDates(); err != nil {
+		return b, fmt.Errorf("book `%v`: %w", b.PageName, err)
+	}
+
+	// ---
+	// Exclude chapters
+	// ---
+	included := make([]Chapter, 0, len(b.Chapters))
+	excluded := make(map[string]string) // reasons by page name
+	var includedBundles []string
+	var excludedAssets []string
+	var projected []string // page names of chapters with a projected date in the future
+	for _, c := range b.Chapters {
+		reason := d.excluded(c.Draft, c.DatePublished, c.DateExpires)
+		if c.DatePublished.After(d.opts.Now) && (reason == "" || reason == "scheduled") {
+			b.Releases = append(b.Releases, Release{PageName: c.PageName, Title: c.Title, Date: c.DatePublished})
+		}
+
+		if reason != "" {
+			d.logger.Info("excluded chapter", "book", b.PageName, "chapter", c.PageName, "reason", reason)
+			excludedAssets = append(excludedAssets, c.Assets...)
+			excluded[c.PageName] = reason
+			if c.projected && reason == "scheduled" {
+				projected = append(projected, c.PageName)
+			}
+			continue
+		}
+
+		// The files of a chapter bundle are only copied if the
+		// chapter is included
+		if bundle, ok := bundles[c.PageName]; ok {
+			if err := b.assets.addDir(bundle); err != nil {
+				return b, fmt.Errorf("book `%v`: failed to read chapter bundle `%v`. %w", b.PageName, path.Base(bundle), err)
+			}
+			includedBundles = append(includedBundles, bundle)
+		}
+
+		included = append(included, c)
+	}
+	b.Chapters = included
+
+	for _, c := range b.Chapters {
+		b.Stats = b.Stats.Add(c.Stats)
+	}
+
+	slices.SortStableFunc(b.Releases, func(x, y Release) int {
+		return x.Date.Compare(y.Date)
+	})
+	b.NextRelease = b.nextRelease(d.opts.Now)
+
+	// Files referenced only by excluded chapters are not copied
+	removed := b.assets.remove(excludedAssets, b.usedAssets())
+	b.images.remove(removed)
+	b.Assets = b.assets.list()
+
 	for i := range len(b.Chapters) {
 		if (i - 1) >= 0 {
 			b.Chapters[i].Previous = &b.Chapters[i-1]
@@ -252,34 +627,166 @@ func DecodeBook(workingDir string, parent *Collection) (Book, error) {
 		}
 	}
 
+	// ---
+	// Collect warnings
+	// ---
+
+	// Pages of excluded chapters are not written, so links to them
+	// are broken
+	for _, name := range b.contentChapters {
+		if reason, ok := excluded[name]; ok {
+			b.Warnings = append(b.Warnings, excludedLinkWarning(name, reason))
+		}
+	}
+
+	// Chapters without a date may have been meant to be published
+	if len(projected) > 0 {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("%v chapters without a publication date are excluded, as their dates projected from the schedule are in the future (`%v`). Set `published` or include them with `%v`.", len(projected), strings.Join(projected, "`, `"), excludedFlags["scheduled"]))
+	}
+
+	for i := range b.Chapters {
+		c := &b.Chapters[i]
+		for _, name := range c.linkedChapters {
+			if reason, ok := excluded[name]; ok {
+				c.Warnings = append(c.Warnings, excludedLinkWarning(name, reason))
+			}
+		}
+	}
+
+	for _, c := range b.Chapters {
+		for _, warning := range c.Warnings {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("chapter `%v`: %v", c.PageName, warning))
+		}
+	}
+
+	for _, name := range b.unusedBundleFiles(includedBundles) {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("file `%v` in chapter bundle is not used by the chapter", name))
+	}
+
 	return b, nil
 }
 
+// Returns the names of the files referenced by the book's content,
+// its chapters and its cover image.
+func (b *Book) usedAssets() []string {
+	used := slices.Clone(b.contentAssets)
+	for _, c := range b.Chapters {
+		used = append(used, c.Assets...)
+	}
+
+	if b.CoverImage != nil {
+		used = append(used, b.CoverImage.Name)
+	}
+
+	return used
+}
+
+// Returns the files in the chapter bundles (directories relative to
+// the book directory) that are not referenced in the content of any
+// chapter, and are not the cover image.
+func (b *Book) unusedBundleFiles(bundles []string) []string {
+	used := make(map[string]bool)
+	for _, c := range b.Chapters {
+		for _, name := range c.Assets {
+			used[name] = true
+		}
+	}
+
+	if b.CoverImage != nil {
+		used[b.CoverImage.Name] = true
+	}
+
+	var unused []string
+	for _, name := range b.Assets {
+		if used[name] {
+			continue
+		}
+
+		for _, bundle := range bundles {
+			if strings.HasPrefix(name, bundle+"/") {
+				unused = append(unused, name)
+				break
+			}
+		}
+	}
+
+	return unused
+}
+
 // Decode file path with .md extension into a Chapter.
-func DecodeChapter(path string, parent *Book) (Chapter, error) {
-	if filepath.Ext(path) != ".md" {
-		return Chapter{}, fmt.Errorf("chapter %v: missing `.md` (markdown) file extension", filepath.Base(path), path)
+func DecodeChapter(file string, parent *Book) (Chapter, error) {
+	// Read chapters of a book from the same filesystem as the book,
+	// so that relative links in them are resolved in the book
+	// directory.
+	if parent != nil && parent.assets != nil && parent.assets.src.root != "" {
+		rel, err := filepath.Rel(parent.assets.src.root, file)
+		if err == nil && filepath.IsLocal(rel) {
+			return backgroundDecoder().decodeChapter(parent.assets.src, filepath.ToSlash(rel), parent)
+		}
+	}
+
+	return backgroundDecoder().decodeChapter(osSource(filepath.Dir(file)), filepath.Base(file), parent)
+}
+
+// DecodeChapterFS is like DecodeChapter, but reads the file at name
+// from fsys instead of the OS filesystem. If parent is not nil, fsys
+// must be the filesystem that the parent was decoded from.
+func DecodeChapterFS(fsys fs.FS, name string, parent *Book) (Chapter, error) {
+	return backgroundDecoder().decodeChapter(source{fsys: fsys}, name, parent)
+}
+
+func (d *decoder) decodeChapter(src source, name string, parent *Book) (Chapter, error) {
+	if err := d.ctx.Err(); err != nil {
+		return Chapter{}, err
+	}
+
+	file := src.path(name)
+	if path.Ext(name) != ".md" {
+		return Chapter{}, fmt.Errorf("chapter %v: missing `.md` (markdown) file extension", path.Base(name))
 	}
 
 	var c Chapter
-	c.InitializeDefaults(path, parent)
+	c.InitializeDefaults(file, parent)
 
-	rawMarkdown, err := os.ReadFile(path)
+	rawMarkdown, err := fs.ReadFile(src.fsys, name)
 	if err != nil {
-		return Chapter{}, fmt.Errorf("chapter `%v`: failed to read file at `%v`. %w", c.PageName, path, err)
+		return Chapter{}, fmt.Errorf("chapter `%v`: failed to read file at `%v`. %w", c.PageName, file, err)
+	}
+
+	markdown := defaultMarkdownConverter
+	var rewriteLink links.Rewriter
+	var resolveImage imagesize.Resolver
+	if parent != nil {
+		markdown = parent.markdownConverter()
+		rewriteLink = parent.assets.rewriter(path.Dir(name), &c.Assets, &c.linkedChapters)
+		resolveImage = parent.images.resolve
 	}
 
-	c.Content.Raw = string(rawMarkdown)
-	contentHTML, metadata, err := convertMarkdownToHTML(rawMarkdown, false)
+	content, metadata, err := markdown.convert(rawMarkdown, rewriteLink, resolveImage)
 	if err != nil {
-		return c, fmt.Errorf("chapter `%v`: failed to convert markdown to HTML. %w", c.PageName, err)
+		return c, fmt.Errorf("chapter `%v`: failed to convert markdown to HTML in `%v`. %w", c.PageName, file, err)
 	}
-	c.Content.HTML = contentHTML
+	c.Content = content
 
 	c.Params = metadata
-	if err := mapstructure.Decode(c.Params, &c); err != nil {
-		return c, fmt.Errorf("chapter `%v`: failed to decode metadata in chapter. %w", c.PageName, err)
+	if c.Params != nil {
+		migrateParams(configKindChapter, parent.configVersion(), c.Params)
+	}
+	dataFrontMatter, format, lineOffset := frontMatter(rawMarkdown)
+	config := &configDecoder{
+		files: []configFile{{
+			path:       file,
+			format:     format,
+			data:       dataFrontMatter,
+			lineOffset: lineOffset,
+		}},
+		strict: !d.opts.Lenient,
+	}
+	warnings, err := config.decode(c.Params, &c)
+	if err != nil {
+		return c, fmt.Errorf("chapter `%v`: failed to decode metadata in chapter.\n%w", c.PageName, err)
 	}
+	c.Warnings = append(c.Warnings, warnings...)
 
 	datePubParam, ok := c.Params["published"]
 	if ok && c.DatePublished.IsZero() {
@@ -297,9 +804,59 @@ func DecodeChapter(path string, parent *Book) (Chapter, error) {
 		}
 	}
 
+	dateExpParam, ok := c.Params["expires"]
+	if ok && c.DateExpires.IsZero() {
+		c.DateExpires, err = getTimeFromParam(dateExpParam)
+		if err != nil {
+			return c, fmt.Errorf("chapter `%v`: failed to parse date expires: %w", c.PageName, err)
+		}
+	}
+
+	c.Summary = newSummary(c.Content, parent.summaryLength())
+	c.Stats = newStats(c.Content, c.LanguageCode, parent.readingTime())
+
+	// Chapters of a book with a schedule get a projected date instead
+	// (see Book.projectChapterDates)
+	if c.DatePublished.IsZero() && (parent == nil || len(parent.Schedule.Days) == 0) {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("missing publication date (`published`) in `%v`", file))
+	}
+
 	return c, nil
 }
 
+// Extensions of configuration files, in the order they are shown in
+// messages.
+var configExtensions = []string{".yml", ".yaml", ".toml", ".json"}
+
+// Returns the name and format of the configuration file named base
+// (e.g. `bookgen`) with one of configExtensions in dir. It is an error
+// if there is none or more than one.
+func findConfigFile(src source, dir, base string) (string, meta.Format, error) {
+	var found []string
+	for _, ext := range configExtensions {
+		name := path.Join(dir, base+ext)
+		if _, err := fs.Stat(src.fsys, name); err == nil {
+			found = append(found, name)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", fmt.Errorf("failed to read file `%v`. %w", src.path(name), err)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", "", fmt.Errorf("failed to find configuration file `%v` (%v) in `%v`. %w", base, strings.Join(configExtensions, " | "), src.path(dir), fs.ErrNotExist)
+	case 1:
+		return found[0], meta.FormatFromExtension(path.Ext(found[0])), nil
+	}
+
+	paths := make([]string, len(found))
+	for i, name := range found {
+		paths[i] = "`" + src.path(name) + "`"
+	}
+
+	return "", "", fmt.Errorf("found multiple configuration files %v in `%v`. Only one may be used.", strings.Join(paths, ", "), src.path(dir))
+}
+
 // Assumes param exists.
 func getTimeFromParam(param any) (time.Time, error) {
 	switch v := param.(type) {
@@ -316,25 +873,27 @@ func getTimeFromParam(param any) (time.Time, error) {
 	}
 }
 
+// Layouts (see time.Layout) of the dates accepted in configuration
+// files and front matter, such as `published`.
+var DateFormats = []string{
+	"2006",
+	"2006-01",
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+}
+
 // Convert a date string input into time. String argument must be in
 // the correct format or it will return an error.
 func stringToTime(sTime string) (time.Time, error) {
-	formats := []string{
-		"2006",
-		"2006-01",
-		"2006-01-02",
-		"2006-01-02 15:04",
-		"2006-01-02T15:04",
-		"2006-01-02 15:04Z07:00",
-		"2006-01-02T15:04Z07:00",
-		"2006-01-02 15:04:05",
-		"2006-01-02T15:04:05",
-		"2006-01-02 15:04:05Z07:00",
-		"2006-01-02T15:04:05Z07:00",
-	}
-
 	var errs error
-	for _, format := range formats {
+	for _, format := range DateFormats {
 		date, err := time.Parse(format, sTime)
 		if err == nil {
 			return date, nil
@@ -345,22 +904,91 @@ func stringToTime(sTime string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("date string `%v` does not match any of the following formats:\n%w", sTime, errs)
 }
 
-func convertMarkdownToHTML(content []byte, useXHTML bool) (template.HTML, map[string]any, error) {
-	var buffer bytes.Buffer
+// Read the files of the book from dir of src instead of the working
+// directory given to Book.InitializeDefaults.
+func (b *Book) setSource(src source, dir string) {
+	b.images = newImageSet(src, dir, b.images.settings)
+	b.assets = newAssetSet(src, dir)
+}
+
+// Returns the markdown converter of the parent Collection, or the
+// default converter if there is none.
+func (b *Book) markdownConverter() *markdownConverter {
+	if b.Parent != nil && b.Parent.markdown != nil {
+		return b.Parent.markdown
+	}
+
+	return defaultMarkdownConverter
+}
+
+// Convert markdown into a Content containing both HTML and XHTML
+// output. The source is only parsed once and then rendered for each
+// output format. rewriteLink and resolveImage may be nil.
+func (m *markdownConverter) convert(source []byte, rewriteLink links.Rewriter, resolveImage imagesize.Resolver) (Content, map[string]any, error) {
+	content := Content{
+		Raw: string(source),
+	}
+
 	context := parser.NewContext()
+	shortcode.SetTemplates(context, m.shortcodes)
+	if rewriteLink != nil {
+		links.SetRewriter(context, rewriteLink)
+	}
+	if resolveImage != nil {
+		imagesize.SetResolver(context, resolveImage)
+	}
+
+	document := m.html.Parser().Parse(text.NewReader(source), parser.WithContext(context))
+	if err := errors.Join(shortcode.Errors(context), mathml.Errors(context), diagram.Errors(context), links.Errors(context), imagesize.Errors(context)); err != nil {
+		return content, nil, err
+	}
+
+	// Code blocks are counted from the document, as a highlighted code
+	// block can be rendered as more than one `<pre>`. Diagrams are
+	// already replaced.
+	_ = ast.Walk(document, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && (n.Kind() == ast.KindFencedCodeBlock || n.Kind() == ast.KindCodeBlock) {
+			content.codeBlocks++
+		}
+		return ast.WalkContinue, nil
+	})
 
-	var md goldmark.Markdown
-	if useXHTML {
-		md = markdownToXHTML
-	} else {
-		md = markdownToHTML
+	// The summary marker is not rendered, and the blocks after it are
+	// removed from document afterwards to render the summary
+	marker := findSummaryMarker(document, source)
+	var rest []ast.Node
+	if marker != nil {
+		for n := marker.NextSibling(); n != nil; n = n.NextSibling() {
+			rest = append(rest, n)
+		}
+		document.RemoveChild(document, marker)
 	}
 
-	if err := md.Convert(content, &buffer, parser.WithContext(context)); err != nil {
-		return template.HTML(""), nil, err
+	var buffer bytes.Buffer
+	if err := m.html.Renderer().Render(&buffer, source, document); err != nil {
+		return content, nil, err
+	}
+	content.HTML = template.HTML(buffer.String())
+
+	buffer.Reset()
+	if err := m.xhtml.Renderer().Render(&buffer, source, document); err != nil {
+		return content, nil, err
+	}
+	content.XHTML = template.HTML(buffer.String())
+
+	if marker != nil {
+		for _, n := range rest {
+			document.RemoveChild(document, n)
+		}
+
+		buffer.Reset()
+		if err := m.html.Renderer().Render(&buffer, source, document); err != nil {
+			return content, nil, err
+		}
+		content.summary = template.HTML(strings.TrimSpace(buffer.String()))
 	}
 
 	metadata := meta.Get(context)
 
-	return template.HTML(buffer.String()), metadata, nil
+	return content, metadata, nil
 }
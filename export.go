@@ -0,0 +1,428 @@
+package bookgen
+
+import (
+	"path"
+	"path/filepath"
+)
+
+// Version of the JSON schema of CollectionExport and BookExport. It is
+// increased whenever a field is removed, renamed or changes meaning;
+// new fields may be added without changing it.
+const ExportSchemaVersion = 1
+
+// CollectionExport is the JSON representation of a Collection
+// (`collection.json`). Books are summarized, with their full contents
+// in separate BookExport files.
+type CollectionExport struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	Title         string              `json:"title"`
+	Description   string              `json:"description,omitempty"`
+	BaseURL       string              `json:"baseURL,omitempty"`
+	LanguageCode  string              `json:"languageCode,omitempty"`
+	Params        map[string]any      `json:"params,omitempty"`
+	Books         []BookSummaryExport `json:"books"`
+	Taxonomies    []TaxonomyExport    `json:"taxonomies"`
+}
+
+// TaxonomyExport is the JSON representation of a Taxonomy.
+type TaxonomyExport struct {
+	Name  string       `json:"name"`
+	Terms []TermExport `json:"terms"`
+}
+
+// TermExport is the JSON representation of a Term. Books and chapters
+// are referenced by their page names.
+type TermExport struct {
+	Name     string              `json:"name"`
+	Slug     string              `json:"slug"`
+	Books    []string            `json:"books"`
+	Chapters []TermChapterExport `json:"chapters"`
+}
+
+// TermChapterExport is a chapter in TermExport.Chapters.
+type TermChapterExport struct {
+	Book     string `json:"book"`
+	PageName string `json:"pageName"`
+}
+
+// BookSummaryExport is the JSON representation of a Book in
+// CollectionExport.Books.
+type BookSummaryExport struct {
+	PageName    string         `json:"pageName"`
+	Title       string         `json:"title"`
+	Subtitle    string         `json:"subtitle,omitempty"`
+	Authors     []AuthorExport `json:"authors"`
+	Description string         `json:"description,omitempty"`
+	CoverImage  *ImageExport   `json:"coverImage,omitempty"`
+	Status      string         `json:"status"`
+	IsStub      bool           `json:"isStub"`
+
+	// Path of the book's BookExport file, relative to `collection.json`.
+	Path string `json:"path"`
+}
+
+// BookExport is the JSON representation of a Book
+// (`books/<pageName>/book.json`).
+type BookExport struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	PageName      string           `json:"pageName"`
+	BaseURL       string           `json:"baseURL,omitempty"`
+	Title         string           `json:"title"`
+	Subtitle      string           `json:"subtitle,omitempty"`
+	TitleSort     string           `json:"titleSort,omitempty"`
+	Authors       []AuthorExport   `json:"authors"`
+	AuthorsSort   string           `json:"authorsSort,omitempty"`
+	Series        *SeriesExport    `json:"series,omitempty"`
+	Description   string           `json:"description,omitempty"`
+	Copyright     string           `json:"copyright,omitempty"`
+	IDs           []string         `json:"ids"`
+	Tags          []string         `json:"tags"`
+	CoverImage    *ImageExport     `json:"coverImage,omitempty"`
+	Status        string           `json:"status"`
+	LanguageCode  string           `json:"languageCode"`
+	Mirrors       []LinkExport     `json:"mirrors"`
+	DatePublished string           `json:"datePublished,omitempty"`
+	DateModified  string           `json:"dateModified,omitempty"`
+	NextRelease   string           `json:"nextRelease,omitempty"`
+	Releases      []ReleaseExport  `json:"releases"`
+	IsStub        bool             `json:"isStub"`
+	Params        map[string]any   `json:"params,omitempty"`
+	Content       ContentExport    `json:"content"`
+	Summary       SummaryExport    `json:"summary"`
+	Stats         StatsExport      `json:"stats"`
+	Assets        []string         `json:"assets"`
+	TOC           []TOCEntryExport `json:"toc"`
+	Chapters      []ChapterExport  `json:"chapters"`
+}
+
+// ChapterExport is the JSON representation of a Chapter in
+// BookExport.Chapters.
+type ChapterExport struct {
+	PageName      string         `json:"pageName"`
+	Title         string         `json:"title"`
+	Subtitle      string         `json:"subtitle,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	Order         int            `json:"order"`
+	Authors       []AuthorExport `json:"authors"`
+	Copyright     string         `json:"copyright,omitempty"`
+	LanguageCode  string         `json:"languageCode"`
+	DatePublished string         `json:"datePublished,omitempty"`
+	DateModified  string         `json:"dateModified,omitempty"`
+	Params        map[string]any `json:"params,omitempty"`
+	Content       ContentExport  `json:"content"`
+	Summary       SummaryExport  `json:"summary"`
+	Stats         StatsExport    `json:"stats"`
+	Assets        []string       `json:"assets"`
+
+	// Page names of the previous and next chapters, replacing the
+	// Chapter.Previous and Chapter.Next pointers.
+	Previous string `json:"previous,omitempty"`
+	Next     string `json:"next,omitempty"`
+}
+
+// ReleaseExport is the JSON representation of a Release.
+type ReleaseExport struct {
+	PageName string `json:"pageName"`
+	Title    string `json:"title"`
+	Date     string `json:"date"`
+}
+
+// TOCEntryExport is an entry in the table of contents of a book.
+type TOCEntryExport struct {
+	PageName string `json:"pageName"`
+	Title    string `json:"title"`
+
+	// Path of the chapter's HTML page, relative to the book directory.
+	Path string `json:"path"`
+}
+
+// ContentExport is the JSON representation of a Content.
+type ContentExport struct {
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+	XHTML    string `json:"xhtml"`
+}
+
+// SummaryExport is the JSON representation of a Summary.
+type SummaryExport struct {
+	HTML      string `json:"html"`
+	Text      string `json:"text"`
+	Truncated bool   `json:"truncated"`
+}
+
+// StatsExport is the JSON representation of Stats.
+type StatsExport struct {
+	Words      int `json:"words"`
+	Characters int `json:"characters"`
+	CodeBlocks int `json:"codeBlocks"`
+	Images     int `json:"images"`
+
+	// Estimated reading time in seconds.
+	ReadingTime float64 `json:"readingTime"`
+}
+
+// Export returns the JSON representation of the statistics.
+func (s Stats) Export() StatsExport {
+	return StatsExport{
+		Words:       s.Words,
+		Characters:  s.Characters,
+		CodeBlocks:  s.CodeBlocks,
+		Images:      s.Images,
+		ReadingTime: s.ReadingTime.Seconds(),
+	}
+}
+
+// AuthorExport is the JSON representation of an Author.
+type AuthorExport struct {
+	Name  string       `json:"name"`
+	About string       `json:"about,omitempty"`
+	Links []LinkExport `json:"links"`
+}
+
+// LinkExport is the JSON representation of a SocialLink.
+type LinkExport struct {
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	IsHyperlink bool   `json:"isHyperlink"`
+}
+
+// SeriesExport is the JSON representation of a Series.
+type SeriesExport struct {
+	Name   string  `json:"name"`
+	Number float32 `json:"number,omitempty"`
+}
+
+// ImageExport is the JSON representation of an Image. Paths are
+// relative to the book directory.
+type ImageExport struct {
+	Name      string               `json:"name"`
+	Width     int                  `json:"width"`
+	Height    int                  `json:"height"`
+	Variants  []ImageVariantExport `json:"variants"`
+	Thumbnail *ImageVariantExport  `json:"thumbnail,omitempty"`
+}
+
+// ImageVariantExport is the JSON representation of an ImageVariant.
+type ImageVariantExport struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Export returns the JSON representation of the collection.
+func (c *Collection) Export() CollectionExport {
+	e := CollectionExport{
+		SchemaVersion: ExportSchemaVersion,
+		Title:         c.Title,
+		Description:   c.Description,
+		BaseURL:       c.BaseURL,
+		LanguageCode:  c.LanguageCode,
+		Params:        c.Params,
+		Books:         make([]BookSummaryExport, 0, len(c.Books)),
+		Taxonomies:    make([]TaxonomyExport, 0, len(c.Taxonomies)),
+	}
+
+	for _, b := range c.Books {
+		e.Books = append(e.Books, BookSummaryExport{
+			PageName:    b.PageName,
+			Title:       b.Title,
+			Subtitle:    b.Subtitle,
+			Authors:     exportAuthors(b.Authors),
+			Description: b.Description,
+			CoverImage:  exportImage(b.CoverImage),
+			Status:      b.Status,
+			IsStub:      b.IsStub,
+			Path:        path.Join("books", b.PageName, "book.json"),
+		})
+	}
+
+	for _, taxonomy := range c.Taxonomies {
+		t := TaxonomyExport{Name: taxonomy.Name, Terms: make([]TermExport, 0, len(taxonomy.Terms))}
+		for _, term := range taxonomy.Terms {
+			te := TermExport{
+				Name:     term.Name,
+				Slug:     term.Slug,
+				Books:    make([]string, 0, len(term.Books)),
+				Chapters: make([]TermChapterExport, 0, len(term.Chapters)),
+			}
+
+			for _, b := range term.Books {
+				te.Books = append(te.Books, b.PageName)
+			}
+
+			for _, ch := range term.Chapters {
+				chapter := TermChapterExport{PageName: ch.PageName}
+				if ch.Parent != nil {
+					chapter.Book = ch.Parent.PageName
+				}
+				te.Chapters = append(te.Chapters, chapter)
+			}
+
+			t.Terms = append(t.Terms, te)
+		}
+
+		e.Taxonomies = append(e.Taxonomies, t)
+	}
+
+	return e
+}
+
+// Export returns the JSON representation of the book and its
+// chapters.
+func (b *Book) Export() BookExport {
+	e := BookExport{
+		SchemaVersion: ExportSchemaVersion,
+		PageName:      b.PageName,
+		BaseURL:       b.BaseURL,
+		Title:         b.Title,
+		Subtitle:      b.Subtitle,
+		TitleSort:     b.TitleSort,
+		Authors:       exportAuthors(b.Authors),
+		AuthorsSort:   b.AuthorsSort,
+		Description:   b.Description,
+		Copyright:     b.Copyright,
+		IDs:           nonNil(b.IDs),
+		Tags:          nonNil(b.Tags),
+		CoverImage:    exportImage(b.CoverImage),
+		Status:        b.Status,
+		LanguageCode:  b.LanguageCode,
+		Mirrors:       exportLinks(b.Mirrors),
+		DatePublished: formatDate(b.DatePublished),
+		DateModified:  formatDate(b.DateModified),
+		NextRelease:   formatDate(b.NextRelease),
+		Releases:      make([]ReleaseExport, 0, len(b.Releases)),
+		IsStub:        b.IsStub,
+		Params:        b.Params,
+		Content:       exportContent(b.Content),
+		Summary:       exportSummary(b.Summary),
+		Stats:         b.Stats.Export(),
+		Assets:        nonNil(b.Assets),
+		TOC:           make([]TOCEntryExport, 0, len(b.Chapters)),
+		Chapters:      make([]ChapterExport, 0, len(b.Chapters)),
+	}
+
+	if b.Series.Name != "" {
+		e.Series = &SeriesExport{Name: b.Series.Name, Number: b.Series.Number}
+	}
+
+	for _, r := range b.Releases {
+		e.Releases = append(e.Releases, ReleaseExport{
+			PageName: r.PageName,
+			Title:    r.Title,
+			Date:     formatDate(r.Date),
+		})
+	}
+
+	for _, c := range b.Chapters {
+		e.TOC = append(e.TOC, TOCEntryExport{
+			PageName: c.PageName,
+			Title:    c.Title,
+			Path:     c.PageName + ".html",
+		})
+
+		chapter := ChapterExport{
+			PageName:      c.PageName,
+			Title:         c.Title,
+			Subtitle:      c.Subtitle,
+			Description:   c.Description,
+			Order:         c.Order,
+			Authors:       exportAuthors(c.Authors),
+			Copyright:     c.Copyright,
+			LanguageCode:  c.LanguageCode,
+			DatePublished: formatDate(c.DatePublished),
+			DateModified:  formatDate(c.DateModified),
+			Params:        c.Params,
+			Content:       exportContent(c.Content),
+			Summary:       exportSummary(c.Summary),
+			Stats:         c.Stats.Export(),
+			Assets:        nonNil(c.Assets),
+		}
+
+		if c.Previous != nil {
+			chapter.Previous = c.Previous.PageName
+		}
+
+		if c.Next != nil {
+			chapter.Next = c.Next.PageName
+		}
+
+		e.Chapters = append(e.Chapters, chapter)
+	}
+
+	return e
+}
+
+func exportAuthors(authors []Author) []AuthorExport {
+	e := make([]AuthorExport, 0, len(authors))
+	for _, a := range authors {
+		e = append(e, AuthorExport{
+			Name:  a.Name,
+			About: a.About,
+			Links: exportLinks(a.Links),
+		})
+	}
+
+	return e
+}
+
+func exportLinks(links []SocialLink) []LinkExport {
+	e := make([]LinkExport, 0, len(links))
+	for _, l := range links {
+		e = append(e, LinkExport{
+			Name:        l.Name,
+			Address:     l.Address,
+			IsHyperlink: l.IsHyperlink,
+		})
+	}
+
+	return e
+}
+
+func exportImage(img *Image) *ImageExport {
+	if img == nil {
+		return nil
+	}
+
+	e := &ImageExport{
+		Name:     path.Clean(filepath.ToSlash(img.Name)),
+		Width:    img.Width,
+		Height:   img.Height,
+		Variants: make([]ImageVariantExport, 0, len(img.Variants)),
+	}
+
+	for _, v := range img.Variants {
+		e.Variants = append(e.Variants, ImageVariantExport(v))
+	}
+
+	if img.Thumbnail != nil {
+		thumbnail := ImageVariantExport(*img.Thumbnail)
+		e.Thumbnail = &thumbnail
+	}
+
+	return e
+}
+
+func exportContent(c Content) ContentExport {
+	return ContentExport{
+		Markdown: c.Raw,
+		HTML:     string(c.HTML),
+		XHTML:    string(c.XHTML),
+	}
+}
+
+func exportSummary(s Summary) SummaryExport {
+	return SummaryExport{
+		HTML:      string(s.HTML),
+		Text:      s.Text,
+		Truncated: s.Truncated,
+	}
+}
+
+// Empty slices are written as `[]` instead of `null`.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+
+	return s
+}
@@ -3,15 +3,15 @@ module github.com/JessebotX/bookgen
 go 1.24.1
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/alecthomas/chroma/v2 v2.19.0
 	github.com/go-viper/mapstructure/v2 v2.3.0
 	github.com/goccy/go-yaml v1.18.0
 	github.com/tdewolff/minify/v2 v2.23.8
+	github.com/tdewolff/parse/v2 v2.8.1
 	github.com/yuin/goldmark v1.7.12
+	golang.org/x/image v0.25.0
 	golang.org/x/sync v0.15.0
 )
 
-require (
-	github.com/dlclark/regexp2 v1.11.5 // indirect
-	github.com/tdewolff/parse/v2 v2.8.1 // indirect
-)
+require github.com/dlclark/regexp2 v1.11.5 // indirect
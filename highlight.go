@@ -0,0 +1,153 @@
+package bookgen
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+
+	"github.com/JessebotX/bookgen/internal/highlighting"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/util"
+)
+
+// Options passed to the highlighting extension.
+func (h *Highlighting) options(style string) []highlighting.Option {
+	return []highlighting.Option{
+		highlighting.WithStyle(style),
+		highlighting.WithGuessLanguage(h.GuessLanguage),
+		highlighting.WithFormatOptions(
+			chromahtml.WithLineNumbers(h.LineNumbers),
+			chromahtml.WithClasses(h.Classes),
+			chromahtml.TabWidth(h.TabWidth),
+		),
+		highlighting.WithCodeBlockOptions(codeBlockOptions),
+	}
+}
+
+// Stylesheet returns the CSS rules for code blocks highlighted with
+// the given chroma style. It is only needed when Highlighting.Classes
+// is enabled.
+func (h *Highlighting) Stylesheet(style string) ([]byte, error) {
+	var css bytes.Buffer
+
+	// The highlighting extension writes the stylesheet of every code
+	// block it renders, so render a small code block to get it.
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			highlighting.NewHighlighting(append(
+				h.options(style),
+				highlighting.WithCSSWriter(&css),
+			)...),
+		),
+	)
+
+	var discard bytes.Buffer
+	if err := md.Convert([]byte("```text\n.\n```\n"), &discard); err != nil {
+		return nil, err
+	}
+
+	return css.Bytes(), nil
+}
+
+// codeBlockOptions adds chroma options from the attributes of a
+// fenced code block that are not handled by the highlighting
+// extension itself, such as highlighted lines written as a string
+// (e.g. {hl_lines="3-5,8"}).
+func codeBlockOptions(ctx highlighting.CodeBlockContext) []chromahtml.Option {
+	attrs := ctx.Attributes()
+	if attrs == nil {
+		return nil
+	}
+
+	value, ok := attrs.GetString("hl_lines")
+	if !ok {
+		return nil
+	}
+
+	lines, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	baseLineNumber := 1
+	if start, ok := attrs.GetString("linenostart"); ok {
+		if n, ok := start.(float64); ok {
+			baseLineNumber = int(n)
+		}
+	}
+
+	var ranges [][2]int
+	for _, part := range strings.Split(string(lines), ",") {
+		lhs, rhs, isRange := strings.Cut(strings.TrimSpace(part), "-")
+
+		from, err := strconv.Atoi(strings.TrimSpace(lhs))
+		if err != nil {
+			continue
+		}
+
+		to := from
+		if isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(rhs))
+			if err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, [2]int{from + baseLineNumber - 1, to + baseLineNumber - 1})
+	}
+
+	return []chromahtml.Option{chromahtml.HighlightLines(ranges)}
+}
+
+// codeBlockWrapper returns a highlighting.WrapperRenderer that wraps
+// code blocks in a <figure>, with the `title` attribute of the code
+// block as its caption. For HTML output, a hidden copy button is
+// added, which can be shown and hooked up by a theme's scripts.
+func codeBlockWrapper(useXHTML bool) highlighting.WrapperRenderer {
+	return func(w util.BufWriter, ctx highlighting.CodeBlockContext, entering bool) {
+		language, hasLanguage := ctx.Language()
+
+		if !entering {
+			if !ctx.Highlighted() {
+				_, _ = w.WriteString("</code></pre>\n")
+			}
+			_, _ = w.WriteString("</figure>\n")
+			return
+		}
+
+		_, _ = w.WriteString(`<figure class="code-block"`)
+		if hasLanguage {
+			_, _ = w.WriteString(` data-language="`)
+			_, _ = w.Write(util.EscapeHTML(language))
+			_, _ = w.WriteString(`"`)
+		}
+		_, _ = w.WriteString(">\n")
+
+		if attrs := ctx.Attributes(); attrs != nil {
+			if title, ok := attrs.GetString("title"); ok {
+				if t, ok := title.([]byte); ok && len(t) > 0 {
+					_, _ = w.WriteString(`<figcaption class="code-block-title">`)
+					_, _ = w.Write(util.EscapeHTML(t))
+					_, _ = w.WriteString("</figcaption>\n")
+				}
+			}
+		}
+
+		if !useXHTML {
+			_, _ = w.WriteString(`<button type="button" class="code-block-copy" hidden>Copy</button>` + "\n")
+		}
+
+		if !ctx.Highlighted() {
+			_, _ = w.WriteString("<pre><code")
+			if hasLanguage {
+				_, _ = w.WriteString(` class="language-`)
+				_, _ = w.Write(util.EscapeHTML(language))
+				_, _ = w.WriteString(`"`)
+			}
+			_, _ = w.WriteString(">")
+		}
+	}
+}
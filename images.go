@@ -0,0 +1,354 @@
+package bookgen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "image/gif"
+
+	"github.com/JessebotX/bookgen/internal/imagesize"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Image represents an image file in a book's directory, such as the
+// cover image or an image in markdown content, along with the resized
+// variants generated for it.
+type Image struct {
+	// Path of the image relative to the book directory, using forward
+	// slashes.
+	Name   string
+	Width  int
+	Height int
+
+	// Resized variants from Images.Widths. Widths that are not smaller
+	// than the original image are skipped.
+	Variants []ImageVariant
+
+	// Thumbnail of the image with the width of Images.ThumbnailWidth.
+	// Only set for Book.CoverImage.
+	Thumbnail *ImageVariant
+
+	src     source
+	source  string
+	format  string
+	quality int
+	data    []byte
+
+	mu        sync.Mutex
+	requested []ImageVariant
+}
+
+// ImageVariant represents a resized copy of an Image.
+type ImageVariant struct {
+	// Path of the variant relative to the book directory, using
+	// forward slashes.
+	Name   string
+	Width  int
+	Height int
+}
+
+// Read the dimensions of the image at name (relative to dir in src)
+// and calculate its variants.
+func newImage(src source, dir, name string, settings Images) (*Image, error) {
+	source := path.Join(dir, name)
+	f, err := src.fsys.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image `%v`. %w", src.path(source), err)
+	}
+
+	img := &Image{
+		Name:    name,
+		Width:   config.Width,
+		Height:  config.Height,
+		src:     src,
+		source:  source,
+		format:  format,
+		quality: settings.Quality,
+	}
+
+	widths := slices.Clone(settings.Widths)
+	slices.Sort(widths)
+	for _, width := range slices.Compact(widths) {
+		if width < img.Width {
+			img.Variants = append(img.Variants, img.variant(width))
+		}
+	}
+
+	return img, nil
+}
+
+func (i *Image) variant(width int) ImageVariant {
+	if width >= i.Width || width < 1 || i.data != nil {
+		return ImageVariant{Name: i.Name, Width: i.Width, Height: i.Height}
+	}
+
+	// Lossless formats stay lossless, everything else (including WebP,
+	// which can only be decoded) becomes JPEG. The quality of JPEG is
+	// part of the name, so that changing it writes new variants instead
+	// of keeping the ones encoded before.
+	suffix := "-" + strconv.Itoa(width) + "w-q" + strconv.Itoa(i.quality) + ".jpg"
+	if i.format == "png" || i.format == "gif" {
+		suffix = "-" + strconv.Itoa(width) + "w.png"
+	}
+
+	return ImageVariant{
+		Name:   strings.TrimSuffix(i.Name, path.Ext(i.Name)) + suffix,
+		Width:  width,
+		Height: max(1, (i.Height*width+i.Width/2)/i.Width),
+	}
+}
+
+// Resize returns a variant of the image scaled down to width,
+// keeping its aspect ratio. The variant file is written when the book
+// is rendered. If width is not smaller than the image, the original
+// image is returned as the variant.
+func (i *Image) Resize(width int) ImageVariant {
+	v := i.variant(width)
+	if v.Name == i.Name {
+		return v
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !slices.Contains(i.requested, v) {
+		i.requested = append(i.requested, v)
+	}
+
+	return v
+}
+
+// AllVariants returns Image.Variants, Image.Thumbnail and any variants
+// requested with Image.Resize.
+func (i *Image) AllVariants() []ImageVariant {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	variants := slices.Clone(i.Variants)
+	if i.Thumbnail != nil && i.Thumbnail.Name != i.Name {
+		variants = append(variants, *i.Thumbnail)
+	}
+
+	for _, v := range i.requested {
+		if !slices.Contains(variants, v) {
+			variants = append(variants, v)
+		}
+	}
+
+	return variants
+}
+
+// SrcSet returns the value of a `srcset` attribute containing the
+// original image and its variants, with paths relative to the book
+// directory. It is empty if the image has no variants.
+func (i *Image) SrcSet() string {
+	if len(i.Variants) == 0 {
+		return ""
+	}
+
+	candidates := make([]string, 0, len(i.Variants)+1)
+	for _, v := range i.Variants {
+		candidates = append(candidates, escapeImagePath(v.Name)+" "+strconv.Itoa(v.Width)+"w")
+	}
+	candidates = append(candidates, escapeImagePath(i.Name)+" "+strconv.Itoa(i.Width)+"w")
+
+	return strings.Join(candidates, ", ")
+}
+
+// Source returns the path of the original image file, which is empty
+// for generated images.
+func (i *Image) Source() string {
+	if i.source == "" {
+		return ""
+	}
+
+	return i.src.path(i.source)
+}
+
+// Generated returns the contents of an image generated by bookgen
+// (e.g. a placeholder cover), or nil if the image is a file.
+func (i *Image) Generated() []byte {
+	return i.data
+}
+
+// ModTime returns the modification time of the original image file,
+// or the zero time if it is unknown.
+func (i *Image) ModTime() time.Time {
+	if i.source == "" {
+		return time.Time{}
+	}
+
+	info, err := fs.Stat(i.src.fsys, i.source)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// EncodeVariant resizes the image into a variant and returns the
+// encoded file, which is a JPEG or PNG depending on the extension of
+// the variant.
+func (i *Image) EncodeVariant(v ImageVariant) ([]byte, error) {
+	if i.data != nil {
+		return nil, fmt.Errorf("cannot resize generated image `%v`", i.Name)
+	}
+
+	f, err := i.src.fsys.Open(i.source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image `%v`. %w", i.Source(), err)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, v.Width, v.Height))
+	isJPEG := path.Ext(v.Name) == ".jpg"
+	if isJPEG {
+		// JPEG has no transparency, so flatten onto white.
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if isJPEG {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: i.quality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image `%v`. %w", v.Name, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func escapeImagePath(name string) string {
+	return (&url.URL{Path: name}).EscapedPath()
+}
+
+// imageSet holds the images of a book, which are decoded once and
+// shared between the book and its chapters.
+type imageSet struct {
+	mu       sync.Mutex
+	src      source
+	dir      string
+	settings Images
+	images   map[string]*Image
+}
+
+func newImageSet(src source, dir string, settings Images) *imageSet {
+	return &imageSet{
+		src:      src,
+		dir:      dir,
+		settings: settings,
+		images:   make(map[string]*Image),
+	}
+}
+
+// Get the image at name (relative to the book directory), decoding it
+// if it has not been used before.
+func (s *imageSet) get(name string) (*Image, error) {
+	name = path.Clean(filepath.ToSlash(name))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if img, ok := s.images[name]; ok {
+		return img, nil
+	}
+
+	img, err := newImage(s.src, s.dir, name, s.settings)
+	if err != nil {
+		return nil, err
+	}
+
+	s.images[name] = img
+	return img, nil
+}
+
+// Remove the images with the given names (relative to the book
+// directory), so that they are not processed.
+func (s *imageSet) remove(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		delete(s.images, path.Clean(filepath.ToSlash(name)))
+	}
+}
+
+// Resolve an image destination in markdown content. Remote images
+// and files outside of the book directory are ignored.
+func (s *imageSet) resolve(destination string) (*imagesize.Info, error) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || path.IsAbs(u.Path) {
+		return nil, nil
+	}
+
+	name := path.Clean(u.Path)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return nil, nil
+	}
+
+	if _, err := fs.Stat(s.src.fsys, path.Join(s.dir, name)); err != nil {
+		return nil, nil
+	}
+
+	img, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imagesize.Info{
+		Width:  img.Width,
+		Height: img.Height,
+		SrcSet: img.SrcSet(),
+	}, nil
+}
+
+// Images returns all images used by the book and its chapters, sorted
+// by name.
+func (b *Book) Images() []*Image {
+	if b.images == nil {
+		return nil
+	}
+
+	b.images.mu.Lock()
+	defer b.images.mu.Unlock()
+
+	images := make([]*Image, 0, len(b.images.images))
+	for _, img := range b.images.images {
+		images = append(images, img)
+	}
+
+	slices.SortFunc(images, func(x, y *Image) int {
+		return strings.Compare(x.Name, y.Name)
+	})
+
+	return images
+}
@@ -0,0 +1,342 @@
+// This goldmark extension adds admonitions (also known as callouts or
+// alerts), which are blocks of content that are set apart from the
+// main text, such as notes, tips and warnings.
+//
+// Admonitions can be written as GitHub-style alerts:
+//
+//	> [!NOTE]
+//	> Useful information that readers should know.
+//
+// or as fenced containers with an optional title:
+//
+//	:::warning Mind the gap
+//	Content is parsed as *markdown*.
+//	:::
+//
+// Fenced containers can be nested by using a longer fence for the
+// outer container (e.g. `::::` around a `:::` block).
+//
+// Admonitions are rendered as a <div> with semantic classes and an
+// ARIA role. The markup is well-formed in both HTML and XHTML, so it
+// can be used as-is in EPUB content documents.
+package admonition
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	// GitHub-style alert kinds that are recognized inside block
+	// quotes (case-insensitive).
+	AlertKinds = []string{"note", "tip", "important", "warning", "caution"}
+
+	// ARIA roles for each admonition kind. Kinds that are not listed
+	// use the `note` role.
+	Roles = map[string]string{
+		"note":      "note",
+		"info":      "note",
+		"important": "note",
+		"tip":       "doc-tip",
+		"hint":      "doc-tip",
+		"warning":   "doc-notice",
+		"caution":   "doc-notice",
+		"danger":    "doc-notice",
+	}
+)
+
+// KindAdmonition is a NodeKind of the Admonition node.
+var KindAdmonition = gast.NewNodeKind("Admonition")
+
+// Admonition is a block of content set apart from the main text.
+type Admonition struct {
+	gast.BaseBlock
+
+	// AdmonitionKind is the lowercase kind of admonition (e.g.
+	// `note`, `warning`).
+	AdmonitionKind string
+
+	// Title shown at the top of the admonition. If empty, then a
+	// title is made from AdmonitionKind.
+	Title string
+
+	fenceLength int
+}
+
+// NewAdmonition returns a new Admonition node.
+func NewAdmonition(kind, title string) *Admonition {
+	return &Admonition{
+		AdmonitionKind: strings.ToLower(kind),
+		Title:          title,
+	}
+}
+
+// Kind implements Node.Kind.
+func (n *Admonition) Kind() gast.NodeKind {
+	return KindAdmonition
+}
+
+// Dump implements Node.Dump.
+func (n *Admonition) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{
+		"AdmonitionKind": n.AdmonitionKind,
+		"Title":          n.Title,
+	}, nil)
+}
+
+// DisplayTitle returns Title, or the capitalized AdmonitionKind if
+// Title is empty.
+func (n *Admonition) DisplayTitle() string {
+	if n.Title != "" {
+		return n.Title
+	}
+
+	if n.AdmonitionKind == "" {
+		return ""
+	}
+
+	return strings.ToUpper(n.AdmonitionKind[:1]) + n.AdmonitionKind[1:]
+}
+
+// Role returns the ARIA role of the admonition.
+func (n *Admonition) Role() string {
+	if role, ok := Roles[n.AdmonitionKind]; ok {
+		return role
+	}
+
+	return "note"
+}
+
+func fenceLength(line []byte) int {
+	i := 0
+	for i < len(line) && line[i] == ':' {
+		i++
+	}
+	return i
+}
+
+type containerParser struct {
+}
+
+var defaultContainerParser = &containerParser{}
+
+// NewContainerParser returns a BlockParser that parses fenced
+// `:::kind` admonition containers.
+func NewContainerParser() parser.BlockParser {
+	return defaultContainerParser
+}
+
+func (b *containerParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (b *containerParser) Open(parent gast.Node, reader text.Reader, pc parser.Context) (gast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w > 3 {
+		return nil, parser.NoChildren
+	}
+	line = line[pos:]
+
+	n := fenceLength(line)
+	if n < 3 {
+		return nil, parser.NoChildren
+	}
+
+	rest := util.TrimLeftSpace(line[n:])
+	end := 0
+	for end < len(rest) && (util.IsAlphaNumeric(rest[end]) || rest[end] == '-' || rest[end] == '_') {
+		end++
+	}
+	if end == 0 {
+		return nil, parser.NoChildren
+	}
+
+	kind := string(rest[:end])
+	title := string(util.TrimRightSpace(util.TrimLeftSpace(rest[end:])))
+
+	node := NewAdmonition(kind, title)
+	node.fenceLength = n
+
+	reader.AdvanceToEOL()
+	return node, parser.HasChildren
+}
+
+func (b *containerParser) Continue(node gast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*Admonition)
+
+	line, segment := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w <= 3 {
+		trimmed := line[pos:]
+		if l := fenceLength(trimmed); l == n.fenceLength && util.IsBlank(trimmed[l:]) {
+			reader.Advance(segment.Len() - 1)
+			return parser.Close
+		}
+	}
+
+	return parser.Continue | parser.HasChildren
+}
+
+func (b *containerParser) Close(node gast.Node, reader text.Reader, pc parser.Context) {
+	// nothing to do
+}
+
+func (b *containerParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *containerParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type alertTransformer struct {
+}
+
+var defaultAlertTransformer = &alertTransformer{}
+
+// NewAlertTransformer returns an ASTTransformer that turns block
+// quotes starting with a `[!KIND]` marker into admonitions.
+func NewAlertTransformer() parser.ASTTransformer {
+	return defaultAlertTransformer
+}
+
+// alertKind returns the kind of alert if line is a `[!KIND]` marker.
+func alertKind(line []byte) (string, bool) {
+	line = util.TrimRightSpace(util.TrimLeftSpace(line))
+	if !bytes.HasPrefix(line, []byte("[!")) || !bytes.HasSuffix(line, []byte("]")) {
+		return "", false
+	}
+
+	kind := strings.ToLower(string(line[2 : len(line)-1]))
+	for _, k := range AlertKinds {
+		if k == kind {
+			return kind, true
+		}
+	}
+
+	return "", false
+}
+
+func (a *alertTransformer) Transform(node *gast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var quotes []*gast.Blockquote
+	_ = gast.Walk(node, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if q, ok := n.(*gast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return gast.WalkContinue, nil
+	})
+
+	for _, quote := range quotes {
+		paragraph, ok := quote.FirstChild().(*gast.Paragraph)
+		if !ok || paragraph.Lines().Len() == 0 {
+			continue
+		}
+
+		firstLine := paragraph.Lines().At(0)
+		kind, ok := alertKind(firstLine.Value(source))
+		if !ok {
+			continue
+		}
+
+		// Remove the inline nodes that make up the marker.
+		for child := paragraph.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			paragraph.RemoveChild(paragraph, child)
+
+			if t, ok := child.(*gast.Text); ok && (t.SoftLineBreak() || t.HardLineBreak()) {
+				break
+			}
+			child = next
+		}
+
+		if paragraph.ChildCount() == 0 {
+			quote.RemoveChild(quote, paragraph)
+		} else {
+			lines := paragraph.Lines()
+			lines.SetSliced(1, lines.Len())
+		}
+
+		admonition := NewAdmonition(kind, "")
+		for child := quote.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			admonition.AppendChild(admonition, child)
+			child = next
+		}
+
+		quote.Parent().ReplaceChild(quote.Parent(), quote, admonition)
+	}
+}
+
+// HTMLRenderer is a renderer.NodeRenderer implementation that renders
+// Admonition nodes.
+type HTMLRenderer struct {
+	html.Config
+}
+
+// NewHTMLRenderer returns a new HTMLRenderer.
+func NewHTMLRenderer(opts ...html.Option) renderer.NodeRenderer {
+	r := &HTMLRenderer{
+		Config: html.NewConfig(),
+	}
+	for _, opt := range opts {
+		opt.SetHTMLOption(&r.Config)
+	}
+	return r
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.RegisterFuncs.
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAdmonition, r.renderAdmonition)
+}
+
+func (r *HTMLRenderer) renderAdmonition(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	n := node.(*Admonition)
+	if !entering {
+		_, _ = w.WriteString("</div>\n")
+		return gast.WalkContinue, nil
+	}
+
+	_, _ = w.WriteString(`<div class="admonition admonition-`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.AdmonitionKind)))
+	_, _ = w.WriteString(`" role="`)
+	_, _ = w.WriteString(n.Role())
+	_, _ = w.WriteString("\">\n")
+
+	_, _ = w.WriteString(`<p class="admonition-title">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.DisplayTitle())))
+	_, _ = w.WriteString("</p>\n")
+
+	return gast.WalkContinue, nil
+}
+
+type admonitions struct {
+}
+
+// Admonitions is a goldmark.Extender implementation.
+var Admonitions = &admonitions{}
+
+// Extend implements goldmark.Extender.
+func (e *admonitions) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(NewContainerParser(), 150),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(NewAlertTransformer(), 100),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(NewHTMLRenderer(), 500),
+	))
+}
@@ -0,0 +1,324 @@
+// This goldmark extension renders fenced code blocks written in a
+// diagram language into inline SVG at build time, such as:
+//
+//	```flowchart
+//	start(Start) -> check{Ready?}
+//	check -> done[Ship it] : yes
+//	```
+//
+// Languages are looked up in a registry of pure-Go renderers, which
+// by default contains `flowchart` (a small built-in language), `dot`
+// (a subset of Graphviz DOT) and `pikchr` (a subset of Pikchr). Other
+// languages can be added with Register. Code blocks in any other
+// language are left for the syntax highlighter.
+//
+// Rendered SVG is cached by a hash of the language and source, so
+// unchanged diagrams are only rendered once per process. The cache
+// holds up to 16 MB of SVG, after which the oldest diagrams are
+// evicted.
+package diagram
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Renderer renders diagram source code into an SVG document. The id
+// is unique to the source and should be used to prefix any element
+// IDs in the SVG.
+type Renderer func(source []byte, id string) ([]byte, error)
+
+var (
+	errorsKey = parser.NewContextKey()
+
+	renderersMutex sync.RWMutex
+	renderers      = map[string]Renderer{
+		"flowchart": flowchart,
+		"dot":       dot,
+		"graphviz":  dot,
+		"pikchr":    pikchr,
+	}
+
+	cache = svgCache{entries: map[string][]byte{}}
+)
+
+// Size in bytes of the rendered SVG kept in the cache.
+const cacheSize = 16 << 20
+
+// svgCache holds rendered SVG by key, evicting the oldest entries
+// once it holds more than cacheSize bytes.
+type svgCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+	size    int
+}
+
+func (c *svgCache) load(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	svg, ok := c.entries[key]
+	return svg, ok
+}
+
+func (c *svgCache) store(key string, svg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	c.entries[key] = svg
+	c.order = append(c.order, key)
+	c.size += len(svg)
+
+	for c.size > cacheSize && len(c.order) > 1 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.entries[oldest])
+		delete(c.entries, oldest)
+	}
+}
+
+// Register adds (or replaces) the renderer for a code block language
+// (case-insensitive).
+func Register(language string, r Renderer) {
+	renderersMutex.Lock()
+	defer renderersMutex.Unlock()
+
+	renderers[strings.ToLower(language)] = r
+}
+
+func lookup(language string) (Renderer, bool) {
+	renderersMutex.RLock()
+	defer renderersMutex.RUnlock()
+
+	r, ok := renderers[strings.ToLower(language)]
+	return r, ok
+}
+
+// Render renders source written in language into SVG, using the
+// cached result if the same source has been rendered before.
+func Render(language string, source []byte) ([]byte, error) {
+	r, ok := lookup(language)
+	if !ok {
+		return nil, fmt.Errorf("unknown diagram language `%s`", language)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(strings.ToLower(language)))
+	hash.Write([]byte{0})
+	hash.Write(source)
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	if svg, ok := cache.load(key); ok {
+		return svg, nil
+	}
+
+	svg, err := r(source, "diagram-"+key[:12])
+	if err != nil {
+		return nil, err
+	}
+
+	cache.store(key, svg)
+	return svg, nil
+}
+
+// LineError describes a problem at a line of diagram source, starting
+// at 1. Renderers return it so that the problem is reported at its line
+// in the markdown source.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Error describes a diagram that could not be rendered at a line in
+// the markdown source: the line of the problem if it is known, or else
+// the line of the code fence.
+type Error struct {
+	Line     int
+	Language string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s diagram: %v", e.Line, e.Language, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns all diagram errors found while parsing, or nil if
+// there were none.
+func Errors(pc parser.Context) error {
+	v := pc.Get(errorsKey)
+	if v == nil {
+		return nil
+	}
+
+	return errors.Join(v.([]error)...)
+}
+
+func addError(pc parser.Context, err error) {
+	var errs []error
+	if v := pc.Get(errorsKey); v != nil {
+		errs = v.([]error)
+	}
+
+	pc.Set(errorsKey, append(errs, err))
+}
+
+// KindDiagram is a NodeKind of the Diagram node.
+var KindDiagram = gast.NewNodeKind("Diagram")
+
+// Diagram is a rendered diagram that replaces a fenced code block.
+type Diagram struct {
+	gast.BaseBlock
+
+	Language string
+	SVG      []byte
+}
+
+// Kind implements Node.Kind.
+func (n *Diagram) Kind() gast.NodeKind {
+	return KindDiagram
+}
+
+// Dump implements Node.Dump.
+func (n *Diagram) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"Language": n.Language}, nil)
+}
+
+type astTransformer struct {
+}
+
+var defaultASTTransformer = &astTransformer{}
+
+// NewASTTransformer returns an ASTTransformer that replaces fenced
+// code blocks written in a diagram language with Diagram nodes.
+func NewASTTransformer() parser.ASTTransformer {
+	return defaultASTTransformer
+}
+
+func (a *astTransformer) Transform(node *gast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var blocks []*gast.FencedCodeBlock
+	_ = gast.Walk(node, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if b, ok := n.(*gast.FencedCodeBlock); ok && entering {
+			if language := b.Language(source); language != nil {
+				if _, ok := lookup(string(language)); ok {
+					blocks = append(blocks, b)
+				}
+			}
+		}
+		return gast.WalkContinue, nil
+	})
+
+	for _, b := range blocks {
+		language := string(b.Language(source))
+
+		var code bytes.Buffer
+		for i := 0; i < b.Lines().Len(); i++ {
+			line := b.Lines().At(i)
+			code.Write(line.Value(source))
+		}
+
+		svg, err := Render(language, code.Bytes())
+		if err != nil {
+			line := bytes.Count(source[:b.Info.Segment.Start], []byte{'\n'}) + 1
+
+			// Lines of the code start after the fence
+			var lineErr *LineError
+			if errors.As(err, &lineErr) {
+				line += lineErr.Line
+				err = lineErr.Err
+			}
+
+			addError(pc, &Error{Line: line, Language: language, Err: err})
+			continue
+		}
+
+		b.Parent().ReplaceChild(b.Parent(), b, &Diagram{
+			Language: strings.ToLower(language),
+			SVG:      svg,
+		})
+	}
+}
+
+// HTMLRenderer is a renderer.NodeRenderer implementation that renders
+// Diagram nodes. The same SVG output is used for both HTML and XHTML.
+type HTMLRenderer struct {
+	html.Config
+}
+
+// NewHTMLRenderer returns a new HTMLRenderer.
+func NewHTMLRenderer(opts ...html.Option) renderer.NodeRenderer {
+	r := &HTMLRenderer{
+		Config: html.NewConfig(),
+	}
+	for _, opt := range opts {
+		opt.SetHTMLOption(&r.Config)
+	}
+	return r
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.RegisterFuncs.
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindDiagram, r.renderDiagram)
+}
+
+func (r *HTMLRenderer) renderDiagram(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	if !entering {
+		return gast.WalkContinue, nil
+	}
+
+	n := node.(*Diagram)
+	_, _ = w.WriteString(`<div class="diagram">`)
+	_, _ = w.Write(n.SVG)
+	_, _ = w.WriteString("</div>\n")
+
+	return gast.WalkSkipChildren, nil
+}
+
+type diagrams struct {
+}
+
+// Diagrams is a goldmark.Extender implementation.
+var Diagrams = &diagrams{}
+
+// Extend implements goldmark.Extender.
+func (e *diagrams) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(NewASTTransformer(), 100),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(NewHTMLRenderer(), 500),
+	))
+}
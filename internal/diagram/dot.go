@@ -0,0 +1,388 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// dot renders a subset of the Graphviz DOT language into SVG. It
+// supports `graph` and `digraph`, node and edge statements (including
+// chains such as `a -> b -> c`), the `label` and `shape` attributes,
+// and `rankdir=LR`. Other attributes and ports (`a:port`) are
+// ignored, and subgraphs are not supported.
+func dot(source []byte, id string) ([]byte, error) {
+	tokens, err := dotTokenize(string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dotParser{tokens: tokens, g: NewGraph()}
+	if err := p.parseGraph(); err != nil {
+		return nil, err
+	}
+
+	if len(p.g.Nodes) == 0 {
+		return nil, fmt.Errorf("graph is empty")
+	}
+
+	return p.g.SVG("diagram diagram-dot", id), nil
+}
+
+type dotToken struct {
+	text string
+	line int
+
+	// Quoted and HTML strings, which are always IDs
+	quoted bool
+}
+
+var (
+	dotIdentifier = regexp.MustCompile(`^[A-Za-z_\x{80}-\x{10FFFF}][A-Za-z_0-9\x{80}-\x{10FFFF}]*$`)
+	dotNumeral    = regexp.MustCompile(`^-?(\.[0-9]+|[0-9]+(\.[0-9]*)?)$`)
+
+	// Keywords, which are not IDs unless quoted
+	dotKeywords = []string{"node", "edge", "graph", "digraph", "subgraph", "strict"}
+)
+
+func dotTokenize(s string) ([]dotToken, error) {
+	var tokens []dotToken
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		line := strings.Count(s[:i], "\n") + 1
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(s[i:], "//") || c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, &LineError{Line: line, Err: errors.New("unterminated comment")}
+			}
+			i += end + 4
+		case strings.HasPrefix(s[i:], "->") || strings.HasPrefix(s[i:], "--"):
+			tokens = append(tokens, dotToken{text: s[i : i+2], line: line})
+			i += 2
+		case strings.ContainsRune("{}[];,=:", c):
+			tokens = append(tokens, dotToken{text: string(c), line: line})
+			i++
+		case c == '<':
+			// HTML string, whose tags are left out of the label
+			var sb strings.Builder
+			depth := 0
+			for ; i < len(s); i++ {
+				switch s[i] {
+				case '<':
+					depth++
+				case '>':
+					depth--
+				default:
+					if depth == 1 {
+						sb.WriteByte(s[i])
+					}
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if i >= len(s) {
+				return nil, &LineError{Line: line, Err: errors.New("unterminated HTML string")}
+			}
+			i++
+			tokens = append(tokens, dotToken{text: strings.Join(strings.Fields(sb.String()), " "), quoted: true, line: line})
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					if s[i] == 'n' {
+						sb.WriteByte(' ')
+						i++
+						continue
+					}
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, &LineError{Line: line, Err: errors.New("unterminated string")}
+			}
+			i++
+			tokens = append(tokens, dotToken{text: sb.String(), quoted: true, line: line})
+		default:
+			start := i
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && !strings.ContainsRune("{}[];,=:<\"", rune(s[i])) && !strings.HasPrefix(s[i:], "->") && !strings.HasPrefix(s[i:], "--") {
+				i++
+			}
+			tokens = append(tokens, dotToken{text: s[start:i], line: line})
+		}
+	}
+
+	return tokens, nil
+}
+
+type dotParser struct {
+	tokens []dotToken
+	pos    int
+	g      *Graph
+}
+
+func (p *dotParser) peek() (dotToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return dotToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *dotParser) next() (dotToken, error) {
+	t, ok := p.peek()
+	if !ok {
+		last := dotToken{line: 1}
+		if len(p.tokens) > 0 {
+			last = p.tokens[len(p.tokens)-1]
+		}
+		return dotToken{}, p.errorf(last, "unexpected end of graph")
+	}
+	p.pos++
+	return t, nil
+}
+
+// Returns an error at the line of token t.
+func (p *dotParser) errorf(t dotToken, format string, a ...any) error {
+	return &LineError{Line: t.line, Err: fmt.Errorf(format, a...)}
+}
+
+// Returns the text of an ID: an identifier, a numeral, or a quoted or
+// HTML string.
+func (p *dotParser) id(t dotToken) (string, error) {
+	if t.quoted || dotNumeral.MatchString(t.text) ||
+		(dotIdentifier.MatchString(t.text) && !slices.Contains(dotKeywords, strings.ToLower(t.text))) {
+		return t.text, nil
+	}
+
+	if t.text == "{" {
+		return "", p.errorf(t, "subgraphs are not supported")
+	}
+	return "", p.errorf(t, "expected an ID but found `%s`", t.text)
+}
+
+// Returns the next ID.
+func (p *dotParser) nextID() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	return p.id(t)
+}
+
+// Returns the ID of a node with an optional port (`id:port` or
+// `id:port:compass`), whose first token is t. Ports are ignored.
+func (p *dotParser) nodeID(t dotToken) (string, error) {
+	id, err := p.id(t)
+	if err != nil {
+		return "", err
+	}
+
+	for range 2 {
+		if !p.isNext(":") {
+			break
+		}
+		p.pos++
+		if _, err := p.nextID(); err != nil {
+			return "", err
+		}
+	}
+
+	return id, nil
+}
+
+func (p *dotParser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.quoted || t.text != text {
+		return p.errorf(t, "expected `%s` but found `%s`", text, t.text)
+	}
+	return nil
+}
+
+func (p *dotParser) isNext(text string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && t.text == text
+}
+
+func (p *dotParser) parseGraph() error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(t.text, "strict") {
+		if t, err = p.next(); err != nil {
+			return err
+		}
+	}
+
+	switch strings.ToLower(t.text) {
+	case "digraph":
+		p.g.Directed = true
+	case "graph":
+		p.g.Directed = false
+	default:
+		return p.errorf(t, "expected `graph` or `digraph` but found `%s`", t.text)
+	}
+
+	if !p.isNext("{") {
+		if _, err := p.nextID(); err != nil { // graph name
+			return err
+		}
+	}
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for !p.isNext("}") {
+		if err := p.parseStatement(); err != nil {
+			return err
+		}
+	}
+	p.pos++
+
+	if p.pos < len(p.tokens) {
+		return p.errorf(p.tokens[p.pos], "unexpected `%s` after end of graph", p.tokens[p.pos].text)
+	}
+
+	return nil
+}
+
+func (p *dotParser) parseStatement() error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if !t.quoted {
+		switch strings.ToLower(t.text) {
+		case ";", ",":
+			return nil
+		case "subgraph", "{":
+			return p.errorf(t, "subgraphs are not supported")
+		case "graph", "node", "edge":
+			if p.isNext("[") {
+				_, err := p.parseAttributes()
+				return err
+			}
+		}
+	}
+
+	// Graph attribute (e.g. rankdir=LR)
+	if p.isNext("=") {
+		key, err := p.id(t)
+		if err != nil {
+			return err
+		}
+		p.pos++
+		value, err := p.nextID()
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(key, "rankdir") {
+			p.g.LeftToRight = strings.EqualFold(value, "LR")
+		}
+		return nil
+	}
+
+	// Node or edge statement
+	id, err := p.nodeID(t)
+	if err != nil {
+		return err
+	}
+	ids := []string{id}
+	for p.isNext("->") || p.isNext("--") {
+		op, _ := p.next()
+		if (op.text == "->") != p.g.Directed {
+			return p.errorf(op, "edge operator `%s` cannot be used in this kind of graph", op.text)
+		}
+
+		to, err := p.next()
+		if err != nil {
+			return err
+		}
+		id, err := p.nodeID(to)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	attrs := map[string]string{}
+	if p.isNext("[") {
+		if attrs, err = p.parseAttributes(); err != nil {
+			return err
+		}
+	}
+
+	if len(ids) == 1 {
+		n := p.g.Node(ids[0])
+		if label, ok := attrs["label"]; ok {
+			n.Label = label
+		}
+		switch strings.ToLower(attrs["shape"]) {
+		case "", "box", "rect", "rectangle", "square":
+		case "ellipse", "oval", "circle":
+			n.Shape = ShapeEllipse
+		case "diamond":
+			n.Shape = ShapeDiamond
+		case "mrecord", "rounded":
+			n.Shape = ShapeRounded
+		default:
+			return p.errorf(t, "unsupported shape `%s`", attrs["shape"])
+		}
+		return nil
+	}
+
+	for i := 1; i < len(ids); i++ {
+		p.g.AddEdge(ids[i-1], ids[i], attrs["label"])
+	}
+
+	return nil
+}
+
+func (p *dotParser) parseAttributes() (map[string]string, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+
+	attrs := map[string]string{}
+	for !p.isNext("]") {
+		if p.isNext(",") || p.isNext(";") {
+			p.pos++
+			continue
+		}
+
+		key, err := p.nextID()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.nextID()
+		if err != nil {
+			return nil, err
+		}
+		attrs[strings.ToLower(key)] = value
+	}
+	p.pos++
+
+	return attrs, nil
+}
@@ -0,0 +1,60 @@
+package diagram
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDotIDs(t *testing.T) {
+	valid := []struct {
+		source string
+		labels []string
+	}{
+		{"digraph { a -> b }", []string{">a<", ">b<"}},
+		{"digraph G { _x1 -> 2.5 -> -.5 }", []string{">_x1<", ">2.5<", ">-.5<"}},
+		{"digraph { \"a b\" -> c; }", []string{">a b<"}},
+		{"digraph { a [label=<<b>Bold</b> text>] }", []string{">Bold text<"}},
+		{"digraph { a:p:n -> b:q }", []string{">a<", ">b<"}},
+		{"graph { rankdir=LR; \"node\" -- \"edge\" }", []string{">node<", ">edge<"}},
+	}
+
+	for _, test := range valid {
+		svg, err := dot([]byte(test.source), "d")
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+		for _, label := range test.labels {
+			if !strings.Contains(string(svg), label) {
+				t.Errorf("%q: SVG does not contain %q", test.source, label)
+			}
+		}
+	}
+
+	invalid := []struct {
+		source string
+		line   int
+	}{
+		{"digraph {\n  a -> ;\n}", 2},
+		{"digraph {\n  a -> b\n  c -> [label=x]\n}", 3},
+		{"digraph {\n  a -> node\n}", 2},
+		{"digraph {\n  a [label=;]\n}", 2},
+		{"digraph {\n  a -> b:\n}", 3},
+		{"digraph {\n  a -> {b c}\n}", 2},
+		{"digraph {\n  1a -> b\n}", 2},
+		{"digraph {\n  a [label=<x]\n}", 2},
+	}
+
+	for _, test := range invalid {
+		_, err := dot([]byte(test.source), "d")
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) {
+			t.Errorf("%q: got error %v, want a LineError", test.source, err)
+			continue
+		}
+		if lineErr.Line != test.line {
+			t.Errorf("%q: got line %d, want %d (%v)", test.source, lineErr.Line, test.line, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// flowchart renders the built-in flowchart language into SVG. Each
+// line is either a direction, a node or a chain of edges:
+//
+//	# comments start with `#`
+//	direction: LR
+//	start(Start) -> check{Ready?}
+//	check -> done[Ship it] : yes
+//	check -> start : no
+//
+// Nodes are referred to by ID, and can be given a label and shape by
+// following the ID with [box], (rounded), {diamond} or ((ellipse)).
+// The default direction is TB (top to bottom).
+func flowchart(source []byte, id string) ([]byte, error) {
+	g := NewGraph()
+
+	for i, line := range strings.Split(string(source), "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(line, "direction:"); ok {
+			switch strings.ToUpper(strings.TrimSpace(value)) {
+			case "TB", "TD":
+				g.LeftToRight = false
+			case "LR":
+				g.LeftToRight = true
+			default:
+				return nil, &LineError{Line: lineNum, Err: fmt.Errorf("unknown direction `%s`, must be TB or LR", strings.TrimSpace(value))}
+			}
+			continue
+		}
+
+		// Edge label is everything after the last ` : `
+		edgeLabel := ""
+		if i := strings.LastIndex(line, " : "); i >= 0 {
+			edgeLabel = strings.TrimSpace(line[i+3:])
+			line = line[:i]
+		}
+
+		parts := strings.Split(line, "->")
+		var previous string
+		for j, part := range parts {
+			nodeID, err := parseFlowchartNode(g, strings.TrimSpace(part))
+			if err != nil {
+				return nil, &LineError{Line: lineNum, Err: err}
+			}
+
+			if j > 0 {
+				g.AddEdge(previous, nodeID, edgeLabel)
+			}
+			previous = nodeID
+		}
+
+		if len(parts) == 1 && edgeLabel != "" {
+			return nil, &LineError{Line: lineNum, Err: errors.New("edge label given without an edge")}
+		}
+	}
+
+	if len(g.Nodes) == 0 {
+		return nil, fmt.Errorf("flowchart is empty")
+	}
+
+	return g.SVG("diagram diagram-flowchart", id), nil
+}
+
+// parseFlowchartNode parses `id`, `id[label]`, `id(label)`,
+// `id{label}` or `id((label))`, adding it to the graph.
+func parseFlowchartNode(g *Graph, s string) (string, error) {
+	end := strings.IndexAny(s, "[({")
+	if end < 0 {
+		end = len(s)
+	}
+
+	nodeID := strings.TrimSpace(s[:end])
+	if nodeID == "" || strings.ContainsAny(nodeID, " \t])}") {
+		return "", fmt.Errorf("invalid node `%s`", s)
+	}
+
+	n := g.Node(nodeID)
+	if end == len(s) {
+		return nodeID, nil
+	}
+
+	shapes := []struct {
+		open, close string
+		shape       Shape
+	}{
+		{"((", "))", ShapeEllipse},
+		{"[", "]", ShapeBox},
+		{"(", ")", ShapeRounded},
+		{"{", "}", ShapeDiamond},
+	}
+
+	rest := s[end:]
+	for _, sh := range shapes {
+		if strings.HasPrefix(rest, sh.open) {
+			if !strings.HasSuffix(rest, sh.close) || len(rest) < len(sh.open)+len(sh.close) {
+				return "", fmt.Errorf("node `%s` is missing closing `%s`", nodeID, sh.close)
+			}
+			n.Label = strings.TrimSpace(rest[len(sh.open) : len(rest)-len(sh.close)])
+			n.Shape = sh.shape
+			return nodeID, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid node `%s`", s)
+}
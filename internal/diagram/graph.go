@@ -0,0 +1,345 @@
+package diagram
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Shape of a node in a graph.
+type Shape int
+
+const (
+	ShapeBox Shape = iota
+	ShapeRounded
+	ShapeDiamond
+	ShapeEllipse
+)
+
+const (
+	fontSize      = 14
+	charWidth     = 7.5
+	nodePaddingX  = 14
+	nodeHeight    = 36
+	diamondHeight = 56
+	rankGap       = 56
+	nodeGap       = 32
+	margin        = 8
+	edgeSpread    = 8
+)
+
+// Node is a vertex of a Graph.
+type Node struct {
+	ID    string
+	Label string
+	Shape Shape
+
+	rank       int
+	x, y, w, h float64
+}
+
+// Edge connects two nodes of a Graph.
+type Edge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// Graph is a simple directed graph that can be laid out and rendered
+// into SVG.
+type Graph struct {
+	// LeftToRight lays out ranks from left to right instead of from
+	// top to bottom.
+	LeftToRight bool
+
+	// Directed draws arrowheads at the end of each edge.
+	Directed bool
+
+	Nodes []*Node
+	Edges []Edge
+
+	index map[string]*Node
+}
+
+// NewGraph returns an empty directed Graph.
+func NewGraph() *Graph {
+	return &Graph{
+		Directed: true,
+		index:    map[string]*Node{},
+	}
+}
+
+// Node returns the node with the given ID, creating it if it does not
+// exist yet.
+func (g *Graph) Node(id string) *Node {
+	if n, ok := g.index[id]; ok {
+		return n
+	}
+
+	n := &Node{ID: id, Label: id}
+	g.index[id] = n
+	g.Nodes = append(g.Nodes, n)
+	return n
+}
+
+// AddEdge adds an edge between two nodes, creating them if needed.
+func (g *Graph) AddEdge(from, to, label string) {
+	g.Node(from)
+	g.Node(to)
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Label: label})
+}
+
+func (g *Graph) hasEdge(from, to string) bool {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// assignRanks places each node in a rank (layer) using the longest
+// path from a source node, ignoring edges that would create a cycle.
+func (g *Graph) assignRanks() {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := map[string]int{}
+	forward := map[string][]string{}
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		for _, e := range g.Edges {
+			if e.From != id {
+				continue
+			}
+			switch state[e.To] {
+			case unvisited:
+				forward[id] = append(forward[id], e.To)
+				visit(e.To)
+			case done:
+				forward[id] = append(forward[id], e.To)
+			}
+		}
+		state[id] = done
+	}
+
+	for _, n := range g.Nodes {
+		if state[n.ID] == unvisited {
+			visit(n.ID)
+		}
+	}
+
+	// Relax ranks until stable. The forward edges form a DAG, so this
+	// finishes in at most len(g.Nodes) passes.
+	for range g.Nodes {
+		changed := false
+		for _, n := range g.Nodes {
+			for _, to := range forward[n.ID] {
+				if t := g.index[to]; t.rank < n.rank+1 {
+					t.rank = n.rank + 1
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+}
+
+func (g *Graph) layout() (width, height float64) {
+	g.assignRanks()
+
+	for _, n := range g.Nodes {
+		n.w = math.Max(float64(len([]rune(n.Label)))*charWidth+2*nodePaddingX, 60)
+		n.h = nodeHeight
+		switch n.Shape {
+		case ShapeDiamond:
+			n.w *= 1.4
+			n.h = diamondHeight
+		case ShapeEllipse:
+			n.w *= 1.2
+		}
+	}
+
+	var ranks [][]*Node
+	for _, n := range g.Nodes {
+		for len(ranks) <= n.rank {
+			ranks = append(ranks, nil)
+		}
+		ranks[n.rank] = append(ranks[n.rank], n)
+	}
+
+	// Order each rank by the average position of its predecessors to
+	// reduce edge crossings.
+	position := map[string]float64{}
+	for r, rank := range ranks {
+		if r > 0 {
+			weight := func(n *Node) float64 {
+				sum, count := 0.0, 0.0
+				for _, e := range g.Edges {
+					if p, ok := position[e.From]; ok && e.To == n.ID && g.index[e.From].rank < r {
+						sum += p
+						count++
+					}
+				}
+				if count == 0 {
+					return math.Inf(1)
+				}
+				return sum / count
+			}
+			slices.SortStableFunc(rank, func(a, b *Node) int {
+				wa, wb := weight(a), weight(b)
+				switch {
+				case wa < wb:
+					return -1
+				case wa > wb:
+					return 1
+				}
+				return 0
+			})
+		}
+		for i, n := range rank {
+			position[n.ID] = float64(i)
+		}
+	}
+
+	// Size of each rank along the main axis (thickness) and across it
+	// (breadth).
+	var maxBreadth float64
+	thickness := make([]float64, len(ranks))
+	breadths := make([]float64, len(ranks))
+	for r, rank := range ranks {
+		for i, n := range rank {
+			along, across := n.h, n.w
+			if g.LeftToRight {
+				along, across = n.w, n.h
+			}
+			thickness[r] = math.Max(thickness[r], along)
+			if i > 0 {
+				breadths[r] += nodeGap
+			}
+			breadths[r] += across
+		}
+		maxBreadth = math.Max(maxBreadth, breadths[r])
+	}
+
+	offset := float64(margin)
+	for r, rank := range ranks {
+		cross := margin + (maxBreadth-breadths[r])/2
+		for _, n := range rank {
+			across := n.w
+			if g.LeftToRight {
+				across = n.h
+			}
+			main := offset + thickness[r]/2
+			second := cross + across/2
+			if g.LeftToRight {
+				n.x, n.y = main, second
+			} else {
+				n.x, n.y = second, main
+			}
+			cross += across + nodeGap
+		}
+		offset += thickness[r] + rankGap
+	}
+
+	length := offset - rankGap + margin
+	breadth := maxBreadth + 2*margin
+	if g.LeftToRight {
+		return length, breadth
+	}
+	return breadth, length
+}
+
+// clip returns the point where a line from the center of n towards
+// (tx, ty) crosses the outline of n.
+func (n *Node) clip(tx, ty float64) (float64, float64) {
+	dx, dy := tx-n.x, ty-n.y
+	if dx == 0 && dy == 0 {
+		return n.x, n.y
+	}
+
+	a, b := n.w/2, n.h/2
+	var t float64
+	switch n.Shape {
+	case ShapeDiamond:
+		t = 1 / (math.Abs(dx)/a + math.Abs(dy)/b)
+	case ShapeEllipse:
+		t = 1 / math.Sqrt((dx/a)*(dx/a)+(dy/b)*(dy/b))
+	default:
+		t = math.Min(a/math.Abs(dx), b/math.Abs(dy))
+	}
+
+	return n.x + dx*t, n.y + dy*t
+}
+
+// SVG lays out the graph and renders it into an SVG document. The
+// class is added to the root <svg> element, and id is used to prefix
+// element IDs so that multiple diagrams can be placed on one page.
+func (g *Graph) SVG(class, id string) []byte {
+	width, height := g.layout()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" class="%s" role="img" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif" font-size="%d">`,
+		html.EscapeString(class), num(width), num(height), num(width), num(height), fontSize)
+
+	if g.Directed {
+		fmt.Fprintf(&sb, `<defs><marker id="%s-arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M0 0L10 5L0 10z" fill="currentColor"/></marker></defs>`, id)
+	}
+
+	for _, e := range g.Edges {
+		from, to := g.index[e.From], g.index[e.To]
+		x1, y1 := from.clip(to.x, to.y)
+		x2, y2 := to.clip(from.x, from.y)
+
+		// Move edges that go both ways between two nodes apart so
+		// they do not overlap.
+		if g.hasEdge(e.To, e.From) && e.From != e.To {
+			dx, dy := x2-x1, y2-y1
+			length := math.Hypot(dx, dy)
+			ox, oy := -dy/length*edgeSpread, dx/length*edgeSpread
+			x1, y1, x2, y2 = x1+ox, y1+oy, x2+ox, y2+oy
+		}
+
+		fmt.Fprintf(&sb, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="currentColor"`, num(x1), num(y1), num(x2), num(y2))
+		if g.Directed {
+			fmt.Fprintf(&sb, ` marker-end="url(#%s-arrow)"`, id)
+		}
+		sb.WriteString(`/>`)
+
+		if e.Label != "" {
+			fmt.Fprintf(&sb, `<text x="%s" y="%s" dx="4" fill="currentColor">%s</text>`, num((x1+x2)/2), num((y1+y2)/2), html.EscapeString(e.Label))
+		}
+	}
+
+	for _, n := range g.Nodes {
+		left, top := n.x-n.w/2, n.y-n.h/2
+		switch n.Shape {
+		case ShapeRounded:
+			fmt.Fprintf(&sb, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" fill="none" stroke="currentColor"/>`, num(left), num(top), num(n.w), num(n.h), num(n.h/2))
+		case ShapeDiamond:
+			fmt.Fprintf(&sb, `<polygon points="%s,%s %s,%s %s,%s %s,%s" fill="none" stroke="currentColor"/>`,
+				num(n.x), num(top), num(left+n.w), num(n.y), num(n.x), num(top+n.h), num(left), num(n.y))
+		case ShapeEllipse:
+			fmt.Fprintf(&sb, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" fill="none" stroke="currentColor"/>`, num(n.x), num(n.y), num(n.w/2), num(n.h/2))
+		default:
+			fmt.Fprintf(&sb, `<rect x="%s" y="%s" width="%s" height="%s" fill="none" stroke="currentColor"/>`, num(left), num(top), num(n.w), num(n.h))
+		}
+
+		fmt.Fprintf(&sb, `<text x="%s" y="%s" text-anchor="middle" dominant-baseline="central" fill="currentColor">%s</text>`, num(n.x), num(n.y), html.EscapeString(n.Label))
+	}
+
+	sb.WriteString(`</svg>`)
+	return []byte(sb.String())
+}
+
+func num(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", f), "0"), ".")
+}
@@ -0,0 +1,1058 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// pikchr renders a subset of the Pikchr language into SVG. Objects
+// are placed one after another in the layout direction, which is
+// changed by the `right`, `down`, `left` and `up` statements:
+//
+//	arrow right 200% "request" above
+//	Server: box "Server" fill lightyellow
+//	arrow
+//	circle "DB"
+//	down
+//	arrow from last circle.s
+//	box "Cache" dashed
+//
+// It supports the `box`, `circle`, `ellipse`, `oval`, `cylinder`,
+// `dot`, `text`, `line`, `arrow` and `move` objects, labels, strings
+// with `above`, `below`, `ljust`, `rjust`, `bold` and `italic`, the
+// `width`, `height`, `radius`, `diameter`, `fill`, `color`, `dashed`,
+// `dotted`, `thick`, `thin` and `invisible` attributes, line
+// segments with `from`, `to`, `then` and directions, `at` and `with`
+// to place blocks, and variables such as `boxwid = 1in`. Expressions
+// and other features of Pikchr are not supported.
+func pikchr(source []byte, id string) ([]byte, error) {
+	tokens, err := pikchrTokenize(string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pikchrParser{
+		direction: "right",
+		vars:      pikchrDefaults(),
+		labels:    map[string]*pikchrObject{},
+	}
+	if err := p.parse(tokens); err != nil {
+		return nil, err
+	}
+
+	if len(p.objects) == 0 {
+		return nil, fmt.Errorf("diagram is empty")
+	}
+
+	return p.svg("diagram diagram-pikchr", id), nil
+}
+
+// Pixels in an inch, the default unit of Pikchr.
+const pikchrInch = 96.0
+
+var pikchrUnits = map[string]float64{
+	"":   pikchrInch,
+	"in": pikchrInch,
+	"cm": pikchrInch / 2.54,
+	"mm": pikchrInch / 25.4,
+	"pt": pikchrInch / 72,
+	"pc": pikchrInch / 6,
+	"px": 1,
+}
+
+// Returns the default sizes of objects in pixels, by the name of
+// their variable.
+func pikchrDefaults() map[string]float64 {
+	defaults := map[string]float64{
+		"boxwid":     0.75,
+		"boxht":      0.5,
+		"boxrad":     0,
+		"circlerad":  0.25,
+		"ellipsewid": 0.75,
+		"ellipseht":  0.5,
+		"ovalwid":    1,
+		"ovalht":     0.5,
+		"cylwid":     0.75,
+		"cylht":      0.5,
+		"cylrad":     0.075,
+		"dotrad":     0.015,
+		"linewid":    0.5,
+		"lineht":     0.5,
+		"movewid":    0.5,
+		"textwid":    0.75,
+		"textht":     0.5,
+	}
+	for name, value := range defaults {
+		defaults[name] = value * pikchrInch
+	}
+
+	return defaults
+}
+
+var pikchrDirections = map[string][2]float64{
+	"right": {1, 0},
+	"down":  {0, 1},
+	"left":  {-1, 0},
+	"up":    {0, -1},
+}
+
+// ---
+// Tokens
+// ---
+
+type pikchrTokenKind int
+
+const (
+	pikchrWord pikchrTokenKind = iota
+	pikchrNumber
+	pikchrPercent
+	pikchrOrdinal
+	pikchrString
+	pikchrPunct
+	pikchrEnd // end of a statement
+)
+
+type pikchrToken struct {
+	kind  pikchrTokenKind
+	text  string
+	value float64 // numbers in pixels, percentages as a fraction
+	line  int
+}
+
+func pikchrTokenize(s string) ([]pikchrToken, error) {
+	var tokens []pikchrToken
+	line := 1
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n' || c == ';':
+			tokens = append(tokens, pikchrToken{kind: pikchrEnd, line: line})
+			if c == '\n' {
+				line++
+			}
+			i++
+		case c == '\\' && strings.HasPrefix(s[i+1:], "\n"):
+			// Statement continues on the next line
+			line++
+			i += 2
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '#' || strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, &LineError{Line: line, Err: errors.New("unterminated comment")}
+			}
+			line += strings.Count(s[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"':
+			var sb strings.Builder
+			start := line
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				if s[i] == '\n' {
+					line++
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, &LineError{Line: start, Err: errors.New("unterminated string")}
+			}
+			i++
+			tokens = append(tokens, pikchrToken{kind: pikchrString, text: sb.String(), line: start})
+		case strings.HasPrefix(s[i:], "<->"):
+			tokens = append(tokens, pikchrToken{kind: pikchrPunct, text: "<->", line: line})
+			i += 3
+		case strings.HasPrefix(s[i:], "->") || strings.HasPrefix(s[i:], "<-"):
+			tokens = append(tokens, pikchrToken{kind: pikchrPunct, text: s[i : i+2], line: line})
+			i += 2
+		case strings.HasPrefix(s[i:], "0x"):
+			start := i
+			i += 2
+			for i < len(s) && isPikchrWordChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, pikchrToken{kind: pikchrWord, text: s[start:i], line: line})
+		case isPikchrNumberStart(s[i:]):
+			start := i
+			if c == '-' {
+				i++
+			}
+			for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+				i++
+			}
+			value, err := strconv.ParseFloat(s[start:i], 64)
+			if err != nil {
+				return nil, &LineError{Line: line, Err: fmt.Errorf("invalid number `%s`", s[start:i])}
+			}
+
+			unitStart := i
+			for i < len(s) && (isPikchrWordChar(s[i]) || s[i] == '%') {
+				i++
+			}
+			unit := s[unitStart:i]
+
+			switch {
+			case unit == "%":
+				tokens = append(tokens, pikchrToken{kind: pikchrPercent, text: s[start:i], value: value / 100, line: line})
+			case unit == "st" || unit == "nd" || unit == "rd" || unit == "th":
+				tokens = append(tokens, pikchrToken{kind: pikchrOrdinal, text: s[start:i], value: value, line: line})
+			default:
+				scale, ok := pikchrUnits[unit]
+				if !ok {
+					return nil, &LineError{Line: line, Err: fmt.Errorf("unknown unit `%s`", unit)}
+				}
+				tokens = append(tokens, pikchrToken{kind: pikchrNumber, text: s[start:i], value: value * scale, line: line})
+			}
+		case isPikchrWordChar(c):
+			start := i
+			for i < len(s) && isPikchrWordChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, pikchrToken{kind: pikchrWord, text: s[start:i], line: line})
+		case strings.ContainsRune(":.,()=", rune(c)):
+			tokens = append(tokens, pikchrToken{kind: pikchrPunct, text: string(c), line: line})
+			i++
+		default:
+			return nil, &LineError{Line: line, Err: fmt.Errorf("unexpected character `%c`", c)}
+		}
+	}
+
+	return append(tokens, pikchrToken{kind: pikchrEnd, line: line}), nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// Returns whether s starts with a number, such as `-1.5in` or `.5`.
+func isPikchrNumberStart(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	s = strings.TrimPrefix(s, ".")
+	return len(s) > 0 && isDigit(s[0])
+}
+
+func isPikchrWordChar(c byte) bool {
+	return c == '_' || isDigit(c) || unicode.IsLetter(rune(c))
+}
+
+// ---
+// Objects
+// ---
+
+type pikchrPoint struct {
+	x, y float64
+}
+
+type pikchrText struct {
+	text         string
+	above, below bool
+	ljust, rjust bool
+	bold, italic bool
+}
+
+type pikchrObject struct {
+	kind string
+
+	// Center and size of blocks, or the bounding box of lines
+	center pikchrPoint
+	w, h   float64
+
+	// Corner radius of boxes, or the radius of the ends of cylinders
+	rad float64
+
+	// Points of lines, arrows and moves
+	path []pikchrPoint
+
+	texts []pikchrText
+
+	fill, color          string
+	dashed, dotted       bool
+	invisible            bool
+	strokeWidth          float64
+	arrowStart, arrowEnd bool
+}
+
+func (o *pikchrObject) isLine() bool {
+	return o.kind == "line" || o.kind == "arrow" || o.kind == "move"
+}
+
+// Returns the point of the object at a corner, such as `n` or `se`, or
+// false if there is no such corner.
+func (o *pikchrObject) corner(name string) (pikchrPoint, bool) {
+	if o.isLine() {
+		switch name {
+		case "start":
+			return o.path[0], true
+		case "end":
+			return o.path[len(o.path)-1], true
+		}
+	}
+
+	dx, dy := 0.0, 0.0
+	switch name {
+	case "c", "center":
+	case "n", "north", "t", "top":
+		dy = -1
+	case "s", "south", "b", "bottom":
+		dy = 1
+	case "e", "east", "right":
+		dx = 1
+	case "w", "west", "left":
+		dx = -1
+	case "ne":
+		dx, dy = 1, -1
+	case "nw":
+		dx, dy = -1, -1
+	case "se":
+		dx, dy = 1, 1
+	case "sw":
+		dx, dy = -1, 1
+	default:
+		return pikchrPoint{}, false
+	}
+
+	// Diagonal corners of round objects are on their outline
+	if (o.kind == "circle" || o.kind == "ellipse") && dx != 0 && dy != 0 {
+		dx, dy = dx*math.Sqrt2/2, dy*math.Sqrt2/2
+	}
+
+	return pikchrPoint{o.center.x + dx*o.w/2, o.center.y + dy*o.h/2}, true
+}
+
+// ---
+// Parser
+// ---
+
+type pikchrParser struct {
+	tokens []pikchrToken
+	pos    int
+
+	direction string
+	cursor    pikchrPoint
+	vars      map[string]float64
+	labels    map[string]*pikchrObject
+	objects   []*pikchrObject
+}
+
+func (p *pikchrParser) peek() pikchrToken {
+	return p.tokens[p.pos]
+}
+
+func (p *pikchrParser) next() pikchrToken {
+	t := p.tokens[p.pos]
+	if t.kind != pikchrEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *pikchrParser) isNext(kind pikchrTokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && (text == "" || strings.EqualFold(t.text, text))
+}
+
+// Returns an error at the line of token t.
+func (p *pikchrParser) errorf(t pikchrToken, format string, a ...any) error {
+	return &LineError{Line: t.line, Err: fmt.Errorf(format, a...)}
+}
+
+func (p *pikchrParser) parse(tokens []pikchrToken) error {
+	p.tokens = tokens
+
+	for p.pos < len(p.tokens)-1 {
+		if p.isNext(pikchrEnd, "") {
+			p.pos++
+			continue
+		}
+
+		if err := p.parseStatement(); err != nil {
+			return err
+		}
+
+		if t := p.next(); t.kind != pikchrEnd {
+			return p.errorf(t, "unexpected `%s`", t.text)
+		}
+	}
+
+	return nil
+}
+
+func (p *pikchrParser) parseStatement() error {
+	t := p.next()
+
+	// Label of an object
+	label := ""
+	if t.kind == pikchrWord && p.isNext(pikchrPunct, ":") {
+		p.pos++
+		label = t.text
+		t = p.next()
+	}
+
+	if t.kind == pikchrString {
+		p.pos--
+		return p.parseObject("text", label)
+	}
+
+	if t.kind != pikchrWord {
+		return p.errorf(t, "unexpected `%s`", t.text)
+	}
+
+	word := strings.ToLower(t.text)
+
+	// Variable (e.g. boxwid = 1in)
+	if label == "" && p.isNext(pikchrPunct, "=") {
+		p.pos++
+		if _, ok := p.vars[word]; !ok {
+			return p.errorf(t, "unknown variable `%s`", t.text)
+		}
+
+		value := p.next()
+		if value.kind != pikchrNumber {
+			return p.errorf(value, "expected a number for `%s`", t.text)
+		}
+		p.vars[word] = value.value
+		return nil
+	}
+
+	if _, ok := pikchrDirections[word]; ok && label == "" {
+		p.direction = word
+		return nil
+	}
+
+	switch word {
+	case "box", "circle", "ellipse", "oval", "cylinder", "cyl", "dot", "text", "line", "arrow", "move":
+		if word == "cyl" {
+			word = "cylinder"
+		}
+		return p.parseObject(word, label)
+	}
+
+	return p.errorf(t, "unknown object `%s`", t.text)
+}
+
+// segment is a part of a line, in a direction or to a place.
+type pikchrSegment struct {
+	direction string
+	length    float64 // 0 for the default length
+	to        *pikchrPoint
+}
+
+func (p *pikchrParser) parseObject(kind, label string) error {
+	o := &pikchrObject{kind: kind, strokeWidth: 1}
+
+	var (
+		width, height, radius float64
+		hasFrom               bool
+		from                  pikchrPoint
+		segments              []pikchrSegment
+		at                    *pikchrPoint
+		with                  = "c"
+	)
+
+	// Defaults of the object
+	switch kind {
+	case "box":
+		width, height, radius = p.vars["boxwid"], p.vars["boxht"], p.vars["boxrad"]
+	case "circle":
+		width, height = 2*p.vars["circlerad"], 2*p.vars["circlerad"]
+	case "ellipse":
+		width, height = p.vars["ellipsewid"], p.vars["ellipseht"]
+	case "oval":
+		width, height = p.vars["ovalwid"], p.vars["ovalht"]
+	case "cylinder":
+		width, height, radius = p.vars["cylwid"], p.vars["cylht"], p.vars["cylrad"]
+	case "dot":
+		width, height = 2*p.vars["dotrad"], 2*p.vars["dotrad"]
+	case "text":
+		width, height = p.vars["textwid"], p.vars["textht"]
+		o.invisible = true
+	case "arrow":
+		o.arrowEnd = true
+	case "move":
+		o.invisible = true
+	}
+	widthSet := false
+
+	for !p.isNext(pikchrEnd, "") {
+		t := p.next()
+
+		switch t.kind {
+		case pikchrString:
+			o.texts = append(o.texts, pikchrText{text: t.text})
+			continue
+		case pikchrPunct:
+			switch t.text {
+			case "->":
+				o.arrowStart, o.arrowEnd = false, true
+				continue
+			case "<-":
+				o.arrowStart, o.arrowEnd = true, false
+				continue
+			case "<->":
+				o.arrowStart, o.arrowEnd = true, true
+				continue
+			}
+			return p.errorf(t, "unexpected `%s`", t.text)
+		case pikchrWord:
+		default:
+			return p.errorf(t, "unexpected `%s`", t.text)
+		}
+
+		word := strings.ToLower(t.text)
+
+		// Modifiers of the last string
+		switch word {
+		case "above", "below", "ljust", "rjust", "bold", "italic", "center":
+			if len(o.texts) == 0 {
+				return p.errorf(t, "`%s` must follow a string", t.text)
+			}
+			text := &o.texts[len(o.texts)-1]
+			switch word {
+			case "above":
+				text.above = true
+			case "below":
+				text.below = true
+			case "ljust":
+				text.ljust = true
+			case "rjust":
+				text.rjust = true
+			case "bold":
+				text.bold = true
+			case "italic":
+				text.italic = true
+			}
+			continue
+		}
+
+		switch word {
+		case "width", "wid", "height", "ht", "radius", "rad", "diameter":
+			current := height
+			if word == "width" || word == "wid" {
+				current = width
+			}
+
+			value, err := p.parseLength(t, current)
+			if err != nil {
+				return err
+			}
+
+			switch word {
+			case "width", "wid":
+				width, widthSet = value, true
+			case "height", "ht":
+				height = value
+			case "radius", "rad":
+				if kind == "circle" || kind == "dot" {
+					width, height, widthSet = 2*value, 2*value, true
+				} else {
+					radius = value
+				}
+			case "diameter":
+				width, height, widthSet = value, value, true
+			}
+		case "fill", "color", "colour":
+			value := p.next()
+			color, ok := pikchrColor(value)
+			if !ok {
+				return p.errorf(value, "invalid color `%s`", value.text)
+			}
+			if word == "fill" {
+				o.fill = color
+			} else {
+				o.color = color
+			}
+		case "dashed":
+			o.dashed = true
+		case "dotted":
+			o.dotted = true
+		case "thick":
+			o.strokeWidth = 2
+		case "thin":
+			o.strokeWidth = 0.5
+		case "invisible", "invis":
+			o.invisible = true
+		case "right", "down", "left", "up":
+			if !o.isLine() {
+				return p.errorf(t, "`%s` can only be used with lines", t.text)
+			}
+
+			segment := pikchrSegment{direction: word}
+			if p.isNext(pikchrNumber, "") {
+				segment.length = p.next().value
+			} else if p.isNext(pikchrPercent, "") {
+				segment.length = p.next().value * p.defaultLength(kind, word)
+			}
+			segments = append(segments, segment)
+		case "then":
+			if !o.isLine() {
+				return p.errorf(t, "`%s` can only be used with lines", t.text)
+			}
+		case "from", "to":
+			if !o.isLine() {
+				return p.errorf(t, "`%s` can only be used with lines", t.text)
+			}
+
+			place, err := p.parsePlace()
+			if err != nil {
+				return err
+			}
+			if word == "from" {
+				hasFrom, from = true, place
+			} else {
+				segments = append(segments, pikchrSegment{to: &place})
+			}
+		case "at":
+			if o.isLine() {
+				return p.errorf(t, "`%s` can only be used with blocks", t.text)
+			}
+
+			place, err := p.parsePlace()
+			if err != nil {
+				return err
+			}
+			at = &place
+		case "with":
+			if o.isLine() {
+				return p.errorf(t, "`%s` can only be used with blocks", t.text)
+			}
+			if !p.isNext(pikchrPunct, ".") {
+				return p.errorf(p.peek(), "expected a corner such as `.nw` after `with`")
+			}
+			p.pos++
+
+			corner := p.next()
+			if _, ok := (&pikchrObject{}).corner(strings.ToLower(corner.text)); !ok || corner.kind != pikchrWord {
+				return p.errorf(corner, "unknown corner `%s`", corner.text)
+			}
+			with = strings.ToLower(corner.text)
+		default:
+			return p.errorf(t, "unknown attribute `%s`", t.text)
+		}
+	}
+
+	// ---
+	// Layout
+	// ---
+	if o.isLine() {
+		start := p.cursor
+		if hasFrom {
+			start = from
+		}
+		if len(segments) == 0 {
+			segments = append(segments, pikchrSegment{direction: p.direction})
+		}
+
+		o.path = []pikchrPoint{start}
+		for _, segment := range segments {
+			last := o.path[len(o.path)-1]
+			if segment.to != nil {
+				o.path = append(o.path, *segment.to)
+				continue
+			}
+
+			length := segment.length
+			if length == 0 {
+				length = p.defaultLength(kind, segment.direction)
+			}
+			d := pikchrDirections[segment.direction]
+			o.path = append(o.path, pikchrPoint{last.x + d[0]*length, last.y + d[1]*length})
+		}
+
+		// Bounding box
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, point := range o.path {
+			minX, minY = min(minX, point.x), min(minY, point.y)
+			maxX, maxY = max(maxX, point.x), max(maxY, point.y)
+		}
+		o.center = pikchrPoint{(minX + maxX) / 2, (minY + maxY) / 2}
+		o.w, o.h = maxX-minX, maxY-minY
+
+		p.cursor = o.path[len(o.path)-1]
+	} else {
+		// Boxes grow to fit their strings if no width is given
+		if !widthSet && kind != "circle" && kind != "dot" {
+			for _, text := range o.texts {
+				width = max(width, textWidth(text.text)+2*nodePaddingX)
+			}
+		}
+		if kind == "text" {
+			height = max(height, float64(len(o.texts))*pikchrLineHeight)
+		}
+
+		o.w, o.h, o.rad = width, height, radius
+
+		d := pikchrDirections[p.direction]
+		if at != nil {
+			// Move the corner `with` to the place
+			corner, _ := (&pikchrObject{kind: kind, w: width, h: height}).corner(with)
+			o.center = pikchrPoint{at.x - corner.x, at.y - corner.y}
+		} else {
+			o.center = pikchrPoint{p.cursor.x + d[0]*width/2, p.cursor.y + d[1]*height/2}
+		}
+
+		p.cursor = pikchrPoint{o.center.x + d[0]*width/2, o.center.y + d[1]*height/2}
+	}
+
+	p.objects = append(p.objects, o)
+	if label != "" {
+		p.labels[label] = o
+	}
+
+	return nil
+}
+
+// Returns the default length of a line in a direction.
+func (p *pikchrParser) defaultLength(kind, direction string) float64 {
+	if kind == "move" {
+		return p.vars["movewid"]
+	}
+	if direction == "up" || direction == "down" {
+		return p.vars["lineht"]
+	}
+	return p.vars["linewid"]
+}
+
+// Parse a length after the attribute t, which is a number or a
+// percentage of current.
+func (p *pikchrParser) parseLength(t pikchrToken, current float64) (float64, error) {
+	value := p.next()
+	switch value.kind {
+	case pikchrNumber:
+		return value.value, nil
+	case pikchrPercent:
+		return value.value * current, nil
+	}
+
+	return 0, p.errorf(value, "expected a length after `%s`", t.text)
+}
+
+// Parse a place: a label, `last`, `previous` or an ordinal such as
+// `2nd box`, optionally followed by a corner (e.g. `Server.ne`), or a
+// point such as `(1in, 0.5in)`.
+func (p *pikchrParser) parsePlace() (pikchrPoint, error) {
+	t := p.next()
+
+	if t.kind == pikchrPunct && t.text == "(" {
+		x := p.next()
+		comma := p.next()
+		y := p.next()
+		end := p.next()
+		if x.kind != pikchrNumber || comma.text != "," || y.kind != pikchrNumber || end.text != ")" {
+			return pikchrPoint{}, p.errorf(t, "invalid point, expected `(x, y)`")
+		}
+		// Pikchr's y axis goes up
+		return pikchrPoint{x.value, -y.value}, nil
+	}
+
+	var o *pikchrObject
+	switch {
+	case t.kind == pikchrWord && (strings.EqualFold(t.text, "last") || strings.EqualFold(t.text, "previous")):
+		kind := p.objectKind(p.peek())
+		if kind != "" {
+			p.pos++
+		}
+		o = p.find(kind, -1)
+	case t.kind == pikchrOrdinal || (t.kind == pikchrWord && strings.EqualFold(t.text, "first")):
+		n := 1
+		if t.kind == pikchrOrdinal {
+			n = int(t.value)
+		}
+		kindToken := p.next()
+		kind := p.objectKind(kindToken)
+		if kind == "" {
+			return pikchrPoint{}, p.errorf(kindToken, "expected an object such as `box` after `%s`", t.text)
+		}
+		o = p.find(kind, n)
+	case t.kind == pikchrWord:
+		o = p.labels[t.text]
+		if o == nil {
+			return pikchrPoint{}, p.errorf(t, "unknown place `%s`", t.text)
+		}
+	default:
+		return pikchrPoint{}, p.errorf(t, "expected a place but found `%s`", t.text)
+	}
+
+	if o == nil {
+		return pikchrPoint{}, p.errorf(t, "no such object")
+	}
+
+	corner := "c"
+	if p.isNext(pikchrPunct, ".") {
+		p.pos++
+		corner = strings.ToLower(p.next().text)
+	}
+
+	point, ok := o.corner(corner)
+	if !ok {
+		return pikchrPoint{}, p.errorf(t, "unknown corner `%s`", corner)
+	}
+
+	return point, nil
+}
+
+// Returns the kind of object named by t, or an empty string.
+func (p *pikchrParser) objectKind(t pikchrToken) string {
+	if t.kind != pikchrWord {
+		return ""
+	}
+
+	switch kind := strings.ToLower(t.text); kind {
+	case "box", "circle", "ellipse", "oval", "cylinder", "dot", "text", "line", "arrow", "move":
+		return kind
+	case "cyl":
+		return "cylinder"
+	}
+
+	return ""
+}
+
+// Returns the nth object of a kind (any kind if it is empty), counting
+// from the last object if n is negative, or nil.
+func (p *pikchrParser) find(kind string, n int) *pikchrObject {
+	count := 0
+	for i := range p.objects {
+		j := i
+		if n < 0 {
+			j = len(p.objects) - 1 - i
+		}
+
+		o := p.objects[j]
+		if kind != "" && o.kind != kind {
+			continue
+		}
+
+		count++
+		if count == n || count == -n {
+			return o
+		}
+	}
+
+	return nil
+}
+
+// Returns a color for SVG from a name (e.g. `lightgray`) or a number
+// (e.g. `0xc0c0c0`).
+func pikchrColor(t pikchrToken) (string, bool) {
+	if t.kind != pikchrWord {
+		return "", false
+	}
+
+	if hex, ok := strings.CutPrefix(strings.ToLower(t.text), "0x"); ok {
+		if _, err := strconv.ParseUint(hex, 16, 32); err != nil || len(hex) != 6 {
+			return "", false
+		}
+		return "#" + hex, true
+	}
+
+	for _, r := range t.text {
+		if !unicode.IsLetter(r) {
+			return "", false
+		}
+	}
+
+	return strings.ToLower(t.text), true
+}
+
+// ---
+// SVG
+// ---
+
+const pikchrLineHeight = fontSize * 1.25
+
+func textWidth(s string) float64 {
+	return float64(len([]rune(s))) * charWidth
+}
+
+// pikchrTextPosition is a string of an object placed in the diagram.
+type pikchrTextPosition struct {
+	text   pikchrText
+	x, y   float64
+	anchor string
+}
+
+// Returns the strings of the object with their position and anchor.
+// Strings are stacked around the center of blocks and the middle of
+// lines.
+func (o *pikchrObject) textPositions() []pikchrTextPosition {
+	cx, cy := o.center.x, o.center.y
+	if o.isLine() {
+		cx, cy = o.midpoint()
+	}
+
+	// Two plain strings of a line go above and below it, as in Pikchr
+	texts := o.texts
+	if o.isLine() && len(texts) == 2 && texts[0] == (pikchrText{text: texts[0].text}) && texts[1] == (pikchrText{text: texts[1].text}) {
+		texts = []pikchrText{texts[0], texts[1]}
+		texts[0].above, texts[1].below = true, true
+	}
+
+	positions := make([]pikchrTextPosition, len(texts))
+	for i, text := range texts {
+		y := cy + (float64(i)-float64(len(texts)-1)/2)*pikchrLineHeight
+		if text.above {
+			y -= pikchrLineHeight / 2
+		} else if text.below {
+			y += pikchrLineHeight / 2
+		}
+
+		anchor := "middle"
+		if text.ljust {
+			anchor = "start"
+		} else if text.rjust {
+			anchor = "end"
+		}
+
+		positions[i] = pikchrTextPosition{text: text, x: cx, y: y, anchor: anchor}
+	}
+
+	return positions
+}
+
+// Returns the point halfway along the path of a line.
+func (o *pikchrObject) midpoint() (float64, float64) {
+	total := 0.0
+	for i := 1; i < len(o.path); i++ {
+		total += math.Hypot(o.path[i].x-o.path[i-1].x, o.path[i].y-o.path[i-1].y)
+	}
+
+	remaining := total / 2
+	for i := 1; i < len(o.path); i++ {
+		a, b := o.path[i-1], o.path[i]
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		if length >= remaining && length > 0 {
+			t := remaining / length
+			return a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t
+		}
+		remaining -= length
+	}
+
+	return o.path[0].x, o.path[0].y
+}
+
+func (p *pikchrParser) svg(class, id string) []byte {
+	// Bounding box of every object and string
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	extend := func(x1, y1, x2, y2 float64) {
+		minX, minY = min(minX, x1), min(minY, y1)
+		maxX, maxY = max(maxX, x2), max(maxY, y2)
+	}
+	for _, o := range p.objects {
+		extend(o.center.x-o.w/2, o.center.y-o.h/2, o.center.x+o.w/2, o.center.y+o.h/2)
+		for _, position := range o.textPositions() {
+			w := textWidth(position.text.text)
+			left := position.x - w/2
+			if position.anchor == "start" {
+				left = position.x
+			} else if position.anchor == "end" {
+				left = position.x - w
+			}
+			extend(left, position.y-pikchrLineHeight/2, left+w, position.y+pikchrLineHeight/2)
+		}
+	}
+	width, height := maxX-minX+2*margin, maxY-minY+2*margin
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" class="%s" role="img" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif" font-size="%d">`,
+		html.EscapeString(class), num(width), num(height), num(width), num(height), fontSize)
+	fmt.Fprintf(&sb, `<defs><marker id="%s-arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M0 0L10 5L0 10z" fill="currentColor"/></marker></defs>`, id)
+	fmt.Fprintf(&sb, `<g transform="translate(%s %s)">`, num(margin-minX), num(margin-minY))
+
+	for _, o := range p.objects {
+		if !o.invisible {
+			o.writeShape(&sb, id)
+		}
+
+		color := "currentColor"
+		if o.color != "" {
+			color = o.color
+		}
+		for _, position := range o.textPositions() {
+			fmt.Fprintf(&sb, `<text x="%s" y="%s" text-anchor="%s" dominant-baseline="central" fill="%s"`, num(position.x), num(position.y), position.anchor, color)
+			if position.text.bold {
+				sb.WriteString(` font-weight="bold"`)
+			}
+			if position.text.italic {
+				sb.WriteString(` font-style="italic"`)
+			}
+			fmt.Fprintf(&sb, `>%s</text>`, html.EscapeString(position.text.text))
+		}
+	}
+
+	sb.WriteString(`</g></svg>`)
+	return []byte(sb.String())
+}
+
+// Write the SVG element of the object's shape.
+func (o *pikchrObject) writeShape(sb *strings.Builder, id string) {
+	stroke, fill := "currentColor", "none"
+	if o.color != "" {
+		stroke = o.color
+	}
+	if o.fill != "" {
+		fill = o.fill
+	} else if o.kind == "dot" {
+		fill = stroke
+	}
+	if o.isLine() {
+		fill = "none"
+	}
+
+	style := fmt.Sprintf(` fill="%s" stroke="%s"`, fill, stroke)
+	if o.strokeWidth != 1 {
+		style += fmt.Sprintf(` stroke-width="%s"`, num(o.strokeWidth))
+	}
+	if o.dashed {
+		style += ` stroke-dasharray="6 4"`
+	} else if o.dotted {
+		style += ` stroke-dasharray="1 3" stroke-linecap="round"`
+	}
+
+	left, top := o.center.x-o.w/2, o.center.y-o.h/2
+	switch o.kind {
+	case "box":
+		fmt.Fprintf(sb, `<rect x="%s" y="%s" width="%s" height="%s"`, num(left), num(top), num(o.w), num(o.h))
+		if o.rad > 0 {
+			fmt.Fprintf(sb, ` rx="%s"`, num(o.rad))
+		}
+		fmt.Fprintf(sb, `%s/>`, style)
+	case "oval":
+		fmt.Fprintf(sb, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s"%s/>`, num(left), num(top), num(o.w), num(o.h), num(min(o.w, o.h)/2), style)
+	case "circle", "ellipse":
+		fmt.Fprintf(sb, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s/>`, num(o.center.x), num(o.center.y), num(o.w/2), num(o.h/2), style)
+	case "dot":
+		fmt.Fprintf(sb, `<circle cx="%s" cy="%s" r="%s"%s/>`, num(o.center.x), num(o.center.y), num(o.w/2), style)
+	case "cylinder":
+		rx, ry := o.w/2, min(o.rad, o.h/2)
+		right, bottom := left+o.w, top+o.h
+		fmt.Fprintf(sb, `<path d="M%s %sA%s %s 0 0 0 %s %sA%s %s 0 0 0 %s %sV%sA%s %s 0 0 0 %s %sV%s"%s/>`,
+			num(left), num(top+ry), num(rx), num(ry), num(right), num(top+ry), num(rx), num(ry), num(left), num(top+ry),
+			num(bottom-ry), num(rx), num(ry), num(right), num(bottom-ry), num(top+ry), style)
+	case "line", "arrow":
+		points := make([]string, len(o.path))
+		for i, point := range o.path {
+			points[i] = num(point.x) + "," + num(point.y)
+		}
+		fmt.Fprintf(sb, `<polyline points="%s"%s`, strings.Join(points, " "), style)
+		if o.arrowStart {
+			fmt.Fprintf(sb, ` marker-start="url(#%s-arrow)"`, id)
+		}
+		if o.arrowEnd {
+			fmt.Fprintf(sb, ` marker-end="url(#%s-arrow)"`, id)
+		}
+		sb.WriteString(`/>`)
+	}
+}
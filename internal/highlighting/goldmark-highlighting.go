@@ -340,7 +340,7 @@ type withCodeBlockOptions struct {
 }
 
 func (o *withCodeBlockOptions) SetConfig(c *renderer.Config) {
-	c.Options[optWrapperRenderer] = o.value
+	c.Options[optCodeBlockOptions] = o.value
 }
 
 func (o *withCodeBlockOptions) SetHighlightingOption(c *Config) {
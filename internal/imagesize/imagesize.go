@@ -0,0 +1,172 @@
+// This goldmark extension adds attributes to images in markdown so
+// browsers can reserve space for them before they load and only
+// download what they need:
+//
+//	<img src="map.png" alt="" width="1600" height="900"
+//	     srcset="map-480w.png 480w, map.png 1600w"
+//	     loading="lazy" decoding="async">
+//
+// `loading` and `decoding` are added to every image. The size and
+// `srcset` come from a Resolver set with SetResolver, which is
+// responsible for finding the image file of a destination. Attributes
+// that are already set are kept.
+package imagesize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Info describes the image file of an image destination.
+type Info struct {
+	Width  int
+	Height int
+
+	// SrcSet is the value of the `srcset` attribute. It is left out
+	// if empty.
+	SrcSet string
+}
+
+// Resolver returns the Info of an image destination, or nil if it is
+// not a local image that can be resolved (e.g. a remote URL).
+type Resolver func(destination string) (*Info, error)
+
+var (
+	resolverKey = parser.NewContextKey()
+	errorsKey   = parser.NewContextKey()
+)
+
+// SetResolver sets the Resolver used while parsing with pc.
+func SetResolver(pc parser.Context, r Resolver) {
+	pc.Set(resolverKey, r)
+}
+
+// Error describes an image that could not be resolved at a line in
+// the markdown source.
+type Error struct {
+	Line        int
+	Destination string
+	Err         error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: image `%s`: %v", e.Line, e.Destination, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns all image errors found while parsing, or nil if there
+// were none.
+func Errors(pc parser.Context) error {
+	v := pc.Get(errorsKey)
+	if v == nil {
+		return nil
+	}
+
+	return errors.Join(v.([]error)...)
+}
+
+func addError(pc parser.Context, err error) {
+	var errs []error
+	if v := pc.Get(errorsKey); v != nil {
+		errs = v.([]error)
+	}
+
+	pc.Set(errorsKey, append(errs, err))
+}
+
+// lineNumber returns the line of the block containing n.
+func lineNumber(source []byte, n gast.Node) int {
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Type() == gast.TypeBlock && p.Lines().Len() > 0 {
+			return bytes.Count(source[:p.Lines().At(0).Start], []byte{'\n'}) + 1
+		}
+	}
+
+	return 0
+}
+
+type astTransformer struct {
+}
+
+var defaultASTTransformer = &astTransformer{}
+
+// NewASTTransformer returns an ASTTransformer that adds attributes to
+// image nodes.
+func NewASTTransformer() parser.ASTTransformer {
+	return defaultASTTransformer
+}
+
+func (a *astTransformer) Transform(node *gast.Document, reader text.Reader, pc parser.Context) {
+	var resolve Resolver
+	if v := pc.Get(resolverKey); v != nil {
+		resolve = v.(Resolver)
+	}
+
+	_ = gast.Walk(node, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		image, ok := n.(*gast.Image)
+		if !ok || !entering {
+			return gast.WalkContinue, nil
+		}
+
+		setDefault(image, "loading", []byte("lazy"))
+		setDefault(image, "decoding", []byte("async"))
+
+		if resolve == nil {
+			return gast.WalkContinue, nil
+		}
+
+		info, err := resolve(string(image.Destination))
+		if err != nil {
+			addError(pc, &Error{
+				Line:        lineNumber(reader.Source(), image),
+				Destination: string(image.Destination),
+				Err:         err,
+			})
+			return gast.WalkContinue, nil
+		}
+
+		if info == nil {
+			return gast.WalkContinue, nil
+		}
+
+		setDefault(image, "width", []byte(strconv.Itoa(info.Width)))
+		setDefault(image, "height", []byte(strconv.Itoa(info.Height)))
+		if info.SrcSet != "" {
+			setDefault(image, "srcset", []byte(info.SrcSet))
+		}
+
+		return gast.WalkContinue, nil
+	})
+}
+
+func setDefault(n gast.Node, name string, value []byte) {
+	if _, ok := n.AttributeString(name); !ok {
+		n.SetAttributeString(name, value)
+	}
+}
+
+type imageSize struct {
+}
+
+// ImageSize is a goldmark.Extender implementation.
+var ImageSize = &imageSize{}
+
+// Extend implements goldmark.Extender.
+func (e *imageSize) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(NewASTTransformer(), 100),
+		),
+	)
+}
@@ -0,0 +1,148 @@
+// This goldmark extension rewrites the destinations of links and
+// images in markdown, such as:
+//
+//	![map](images/map.webp) -> ![map](chapters/images/map.webp)
+//	[next](chapter-2.md#start) -> [next](chapter-2.html#start)
+//
+// Every destination is passed to a Rewriter set with SetRewriter,
+// which decides what the new destination is. Destinations that cannot
+// be rewritten (e.g. because the file does not exist) are reported by
+// Errors along with their line number.
+package links
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Rewriter returns the new destination of a link or image.
+type Rewriter func(destination string) (string, error)
+
+var (
+	rewriterKey = parser.NewContextKey()
+	errorsKey   = parser.NewContextKey()
+)
+
+// SetRewriter sets the Rewriter used while parsing with pc.
+func SetRewriter(pc parser.Context, r Rewriter) {
+	pc.Set(rewriterKey, r)
+}
+
+// Error describes a destination that could not be rewritten at a line
+// in the markdown source.
+type Error struct {
+	Line        int
+	Destination string
+	Err         error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: link `%s`: %v", e.Line, e.Destination, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns all link errors found while parsing, or nil if there
+// were none.
+func Errors(pc parser.Context) error {
+	v := pc.Get(errorsKey)
+	if v == nil {
+		return nil
+	}
+
+	return errors.Join(v.([]error)...)
+}
+
+func addError(pc parser.Context, err error) {
+	var errs []error
+	if v := pc.Get(errorsKey); v != nil {
+		errs = v.([]error)
+	}
+
+	pc.Set(errorsKey, append(errs, err))
+}
+
+// lineNumber returns the line of the block containing n.
+func lineNumber(source []byte, n gast.Node) int {
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Type() == gast.TypeBlock && p.Lines().Len() > 0 {
+			return bytes.Count(source[:p.Lines().At(0).Start], []byte{'\n'}) + 1
+		}
+	}
+
+	return 0
+}
+
+type astTransformer struct {
+}
+
+var defaultASTTransformer = &astTransformer{}
+
+// NewASTTransformer returns an ASTTransformer that rewrites the
+// destinations of link and image nodes.
+func NewASTTransformer() parser.ASTTransformer {
+	return defaultASTTransformer
+}
+
+func (a *astTransformer) Transform(node *gast.Document, reader text.Reader, pc parser.Context) {
+	v := pc.Get(rewriterKey)
+	if v == nil {
+		return
+	}
+	rewrite := v.(Rewriter)
+
+	_ = gast.Walk(node, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if !entering {
+			return gast.WalkContinue, nil
+		}
+
+		var destination *[]byte
+		switch n := n.(type) {
+		case *gast.Link:
+			destination = &n.Destination
+		case *gast.Image:
+			destination = &n.Destination
+		default:
+			return gast.WalkContinue, nil
+		}
+
+		rewritten, err := rewrite(string(*destination))
+		if err != nil {
+			addError(pc, &Error{
+				Line:        lineNumber(reader.Source(), n),
+				Destination: string(*destination),
+				Err:         err,
+			})
+			return gast.WalkContinue, nil
+		}
+		*destination = []byte(rewritten)
+
+		return gast.WalkContinue, nil
+	})
+}
+
+type links struct {
+}
+
+// Links is a goldmark.Extender implementation.
+var Links = &links{}
+
+// Extend implements goldmark.Extender.
+func (e *links) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			// Before other transformers that read destinations, such
+			// as imagesize.
+			util.Prioritized(NewASTTransformer(), 90),
+		),
+	)
+}
@@ -0,0 +1,618 @@
+package mathml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+const namespace = "http://www.w3.org/1998/Math/MathML"
+
+// UnsupportedMacroError is returned when a LaTeX expression contains a
+// macro that cannot be converted into MathML.
+type UnsupportedMacroError struct {
+	Macro string
+}
+
+func (e *UnsupportedMacroError) Error() string {
+	return fmt.Sprintf("unsupported macro `\\%s`", e.Macro)
+}
+
+// Convert a LaTeX math expression into a MathML <math> element. If
+// display is true, then the expression is rendered as a block
+// (display="block"), otherwise it is rendered inline with the
+// surrounding text.
+//
+// Only a common subset of LaTeX is supported: letters, numbers and
+// operators, sub/superscripts, \frac, \sqrt, \left/\right, accents,
+// fonts (e.g. \mathbf), Greek letters, common symbols and the matrix,
+// cases and aligned environments.
+func Convert(tex string, display bool) (string, error) {
+	p := &texParser{src: tex, display: display}
+
+	nodes, err := p.parseSequence()
+	if err != nil {
+		return "", err
+	}
+
+	if p.pos < len(p.src) {
+		return "", fmt.Errorf("unexpected `%s` at offset %d", p.src[p.pos:p.pos+1], p.pos)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<math xmlns="` + namespace + `"`)
+	if display {
+		sb.WriteString(` display="block"`)
+	}
+	sb.WriteString(`><semantics><mrow>`)
+	for _, n := range nodes {
+		sb.WriteString(n.markup)
+	}
+	sb.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	sb.WriteString(html.EscapeString(tex))
+	sb.WriteString(`</annotation></semantics></math>`)
+
+	return sb.String(), nil
+}
+
+type mathNode struct {
+	markup string
+
+	// Scripts are placed above/below the node (instead of to the
+	// side) in display mode, such as with \sum and \lim.
+	limits bool
+}
+
+type texParser struct {
+	src     string
+	pos     int
+	display bool
+}
+
+func (p *texParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *texParser) skipSpaces() {
+	for !p.eof() && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// atCommand reports whether the parser is positioned at the command
+// \name (and not a longer command that starts with name).
+func (p *texParser) atCommand(name string) bool {
+	rest := p.src[p.pos:]
+	if !strings.HasPrefix(rest, `\`+name) {
+		return false
+	}
+
+	rest = rest[len(name)+1:]
+	return rest == "" || !isLetter(rest[0]) || !isLetter(name[len(name)-1])
+}
+
+func (p *texParser) atStop() bool {
+	if p.eof() {
+		return true
+	}
+
+	switch p.src[p.pos] {
+	case '}', '&':
+		return true
+	}
+
+	return p.atCommand("right") || p.atCommand("end") || p.atCommand(`\`)
+}
+
+// parseSequence parses nodes until the end of the source or the end
+// of the current group/cell.
+func (p *texParser) parseSequence() ([]mathNode, error) {
+	var nodes []mathNode
+
+	for {
+		p.skipSpaces()
+		if p.atStop() {
+			return nodes, nil
+		}
+
+		var base mathNode
+		if c := p.src[p.pos]; c == '^' || c == '_' {
+			base = mathNode{markup: "<mrow></mrow>"}
+		} else {
+			var err error
+			base, err = p.parseAtom()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		node, err := p.parseScripts(base)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+}
+
+func (p *texParser) parseScripts(base mathNode) (mathNode, error) {
+	var sub string
+	var sups []string
+	hasSub, hasSup := false, false
+
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			break
+		}
+
+		c := p.src[p.pos]
+		if c == '\'' {
+			p.pos++
+			sups = append(sups, "<mo>&#x2032;</mo>")
+			continue
+		}
+
+		if c != '^' && c != '_' {
+			break
+		}
+		p.pos++
+
+		arg, err := p.parseArgument()
+		if err != nil {
+			return mathNode{}, err
+		}
+
+		if c == '^' {
+			if hasSup {
+				return mathNode{}, fmt.Errorf("double superscript")
+			}
+			sups = append(sups, arg)
+			hasSup = true
+		} else {
+			if hasSub {
+				return mathNode{}, fmt.Errorf("double subscript")
+			}
+			sub = arg
+			hasSub = true
+		}
+	}
+
+	if !hasSub && len(sups) == 0 {
+		return base, nil
+	}
+
+	sup := strings.Join(sups, "")
+	if len(sups) > 1 {
+		sup = "<mrow>" + sup + "</mrow>"
+	}
+
+	under, over, both := "msub", "msup", "msubsup"
+	if base.limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+
+	switch {
+	case hasSub && sup != "":
+		return mathNode{markup: "<" + both + ">" + base.markup + sub + sup + "</" + both + ">"}, nil
+	case hasSub:
+		return mathNode{markup: "<" + under + ">" + base.markup + sub + "</" + under + ">"}, nil
+	default:
+		return mathNode{markup: "<" + over + ">" + base.markup + sup + "</" + over + ">"}, nil
+	}
+}
+
+// parseArgument parses a single-token or {grouped} argument of a
+// command or script.
+func (p *texParser) parseArgument() (string, error) {
+	p.skipSpaces()
+	if p.eof() {
+		return "", fmt.Errorf("missing argument at end of expression")
+	}
+
+	// As in LaTeX, an ungrouped argument is only a single digit
+	// (e.g. \frac12).
+	if c := p.src[p.pos]; isDigit(c) {
+		p.pos++
+		return "<mn>" + string(c) + "</mn>", nil
+	}
+
+	node, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+
+	return node.markup, nil
+}
+
+// parseRawGroup returns the unparsed text within {braces}.
+func (p *texParser) parseRawGroup() (string, error) {
+	p.skipSpaces()
+	if p.eof() || p.src[p.pos] != '{' {
+		return "", fmt.Errorf("expected `{` at offset %d", p.pos)
+	}
+
+	depth := 0
+	start := p.pos + 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := p.src[start:p.pos]
+				p.pos++
+				return text, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("missing closing `}`")
+}
+
+func (p *texParser) parseAtom() (mathNode, error) {
+	c := p.src[p.pos]
+
+	switch {
+	case c == '{':
+		p.pos++
+		nodes, err := p.parseSequence()
+		if err != nil {
+			return mathNode{}, err
+		}
+		if p.eof() || p.src[p.pos] != '}' {
+			return mathNode{}, fmt.Errorf("missing closing `}`")
+		}
+		p.pos++
+		return mathNode{markup: mrow(nodes)}, nil
+	case c == '}':
+		return mathNode{}, fmt.Errorf("unexpected `}` at offset %d", p.pos)
+	case c == '&':
+		return mathNode{}, fmt.Errorf("unexpected `&` outside of an environment")
+	case c == '\\':
+		return p.parseCommand()
+	case isDigit(c) || (c == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1])):
+		start := p.pos
+		for !p.eof() && (isDigit(p.src[p.pos]) || (p.src[p.pos] == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]))) {
+			p.pos++
+		}
+		return mathNode{markup: "<mn>" + p.src[start:p.pos] + "</mn>"}, nil
+	case isLetter(c):
+		p.pos++
+		return mathNode{markup: "<mi>" + string(c) + "</mi>"}, nil
+	case c == '~':
+		p.pos++
+		return mathNode{markup: "<mtext>&#xA0;</mtext>"}, nil
+	case c == '#' || c == '%' || c == '$':
+		return mathNode{}, fmt.Errorf("unexpected `%c` at offset %d", c, p.pos)
+	case c >= 0x80:
+		// Pass through UTF-8 characters as identifiers.
+		start := p.pos
+		p.pos++
+		for !p.eof() && p.src[p.pos]&0xC0 == 0x80 {
+			p.pos++
+		}
+		return mathNode{markup: "<mi>" + p.src[start:p.pos] + "</mi>"}, nil
+	default:
+		p.pos++
+		return mathNode{markup: "<mo>" + html.EscapeString(string(c)) + "</mo>"}, nil
+	}
+}
+
+func (p *texParser) readCommandName() string {
+	p.pos++ // skip backslash
+	if p.eof() {
+		return ""
+	}
+
+	start := p.pos
+	if !isLetter(p.src[p.pos]) {
+		p.pos++
+		return p.src[start:p.pos]
+	}
+
+	for !p.eof() && isLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *texParser) parseCommand() (mathNode, error) {
+	name := p.readCommandName()
+	if name == "" {
+		return mathNode{}, fmt.Errorf("missing command name after `\\`")
+	}
+
+	if s, ok := identifiers[name]; ok {
+		return mathNode{markup: "<mi>" + s + "</mi>"}, nil
+	}
+
+	if s, ok := uprightIdentifiers[name]; ok {
+		return mathNode{markup: `<mi mathvariant="normal">` + s + "</mi>"}, nil
+	}
+
+	if s, ok := operators[name]; ok {
+		return mathNode{markup: "<mo>" + s + "</mo>"}, nil
+	}
+
+	if s, ok := largeOperators[name]; ok {
+		return mathNode{markup: `<mo largeop="true" movablelimits="true">` + s + "</mo>", limits: true}, nil
+	}
+
+	if s, ok := integrals[name]; ok {
+		return mathNode{markup: `<mo largeop="true">` + s + "</mo>"}, nil
+	}
+
+	if s, ok := spaces[name]; ok {
+		return mathNode{markup: `<mspace width="` + s + `"/>`}, nil
+	}
+
+	if _, ok := functions[name]; ok {
+		return mathNode{markup: "<mi>" + name + "</mi>"}, nil
+	}
+
+	if _, ok := limitFunctions[name]; ok {
+		return mathNode{markup: "<mi>" + name + "</mi>", limits: true}, nil
+	}
+
+	if s, ok := accents[name]; ok {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return mathNode{}, err
+		}
+		return mathNode{markup: `<mover accent="true">` + arg + "<mo>" + s + "</mo></mover>"}, nil
+	}
+
+	if variant, ok := fonts[name]; ok {
+		text, err := p.parseRawGroup()
+		if err != nil {
+			return mathNode{}, err
+		}
+		return mathNode{markup: `<mi mathvariant="` + variant + `">` + html.EscapeString(text) + "</mi>"}, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		numerator, err := p.parseArgument()
+		if err != nil {
+			return mathNode{}, err
+		}
+		denominator, err := p.parseArgument()
+		if err != nil {
+			return mathNode{}, err
+		}
+		if name == "binom" {
+			return mathNode{markup: `<mrow><mo>(</mo><mfrac linethickness="0">` + numerator + denominator + `</mfrac><mo>)</mo></mrow>`}, nil
+		}
+		return mathNode{markup: "<mfrac>" + numerator + denominator + "</mfrac>"}, nil
+	case "sqrt":
+		p.skipSpaces()
+		index := ""
+		if !p.eof() && p.src[p.pos] == '[' {
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 {
+				return mathNode{}, fmt.Errorf("missing closing `]` for \\sqrt")
+			}
+			inner := &texParser{src: p.src[p.pos+1 : p.pos+end], display: p.display}
+			nodes, err := inner.parseSequence()
+			if err != nil {
+				return mathNode{}, err
+			}
+			index = mrow(nodes)
+			p.pos += end + 1
+		}
+		radicand, err := p.parseArgument()
+		if err != nil {
+			return mathNode{}, err
+		}
+		if index != "" {
+			return mathNode{markup: "<mroot>" + radicand + index + "</mroot>"}, nil
+		}
+		return mathNode{markup: "<msqrt>" + radicand + "</msqrt>"}, nil
+	case "text", "textrm", "mbox", "textit", "textbf":
+		text, err := p.parseRawGroup()
+		if err != nil {
+			return mathNode{}, err
+		}
+		attr := ""
+		switch name {
+		case "textit":
+			attr = ` mathvariant="italic"`
+		case "textbf":
+			attr = ` mathvariant="bold"`
+		}
+		return mathNode{markup: "<mtext" + attr + ">" + html.EscapeString(text) + "</mtext>"}, nil
+	case "operatorname":
+		text, err := p.parseRawGroup()
+		if err != nil {
+			return mathNode{}, err
+		}
+		return mathNode{markup: "<mi>" + html.EscapeString(text) + "</mi>"}, nil
+	case "overline", "underline":
+		arg, err := p.parseArgument()
+		if err != nil {
+			return mathNode{}, err
+		}
+		if name == "overline" {
+			return mathNode{markup: `<mover accent="true">` + arg + "<mo>&#x203E;</mo></mover>"}, nil
+		}
+		return mathNode{markup: `<munder accentunder="true">` + arg + "<mo>&#x332;</mo></munder>"}, nil
+	case "left":
+		return p.parseLeftRight()
+	case "begin":
+		return p.parseEnvironment()
+	case "right":
+		return mathNode{}, fmt.Errorf("unexpected `\\right` without `\\left`")
+	case "end":
+		return mathNode{}, fmt.Errorf("unexpected `\\end` without `\\begin`")
+	case `\`:
+		return mathNode{}, fmt.Errorf("unexpected `\\\\` outside of an environment")
+	}
+
+	return mathNode{}, &UnsupportedMacroError{Macro: name}
+}
+
+func (p *texParser) parseDelimiter() (string, error) {
+	p.skipSpaces()
+	if p.eof() {
+		return "", fmt.Errorf("missing delimiter")
+	}
+
+	c := p.src[p.pos]
+	if c != '\\' {
+		p.pos++
+		if c == '.' {
+			return "", nil
+		}
+		return html.EscapeString(string(c)), nil
+	}
+
+	name := p.readCommandName()
+	if s, ok := delimiters[name]; ok {
+		return s, nil
+	}
+
+	return "", &UnsupportedMacroError{Macro: name}
+}
+
+func (p *texParser) parseLeftRight() (mathNode, error) {
+	open, err := p.parseDelimiter()
+	if err != nil {
+		return mathNode{}, err
+	}
+
+	nodes, err := p.parseSequence()
+	if err != nil {
+		return mathNode{}, err
+	}
+
+	if !p.atCommand("right") {
+		return mathNode{}, fmt.Errorf("missing `\\right` for `\\left`")
+	}
+	p.readCommandName()
+
+	closing, err := p.parseDelimiter()
+	if err != nil {
+		return mathNode{}, err
+	}
+
+	return mathNode{markup: fence(open, closing, mrow(nodes))}, nil
+}
+
+func (p *texParser) parseEnvironment() (mathNode, error) {
+	name, err := p.parseRawGroup()
+	if err != nil {
+		return mathNode{}, err
+	}
+
+	var open, closing, align string
+	switch name {
+	case "matrix", "smallmatrix":
+	case "pmatrix":
+		open, closing = "(", ")"
+	case "bmatrix":
+		open, closing = "[", "]"
+	case "Bmatrix":
+		open, closing = "{", "}"
+	case "vmatrix":
+		open, closing = "|", "|"
+	case "Vmatrix":
+		open, closing = "&#x2016;", "&#x2016;"
+	case "cases":
+		open, align = "{", "left left"
+	case "aligned", "align", "align*", "split":
+		align = "right left"
+	default:
+		return mathNode{}, fmt.Errorf("unsupported environment `%s`", name)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<mtable")
+	if align != "" {
+		sb.WriteString(` columnalign="` + align + `"`)
+	}
+	sb.WriteString(">")
+
+	for {
+		sb.WriteString("<mtr>")
+		for {
+			nodes, err := p.parseSequence()
+			if err != nil {
+				return mathNode{}, err
+			}
+			sb.WriteString("<mtd>" + mrow(nodes) + "</mtd>")
+
+			if !p.eof() && p.src[p.pos] == '&' {
+				p.pos++
+				continue
+			}
+			break
+		}
+		sb.WriteString("</mtr>")
+
+		if p.atCommand(`\`) {
+			p.pos += 2
+			continue
+		}
+		break
+	}
+	sb.WriteString("</mtable>")
+
+	if !p.atCommand("end") {
+		return mathNode{}, fmt.Errorf("missing `\\end{%s}`", name)
+	}
+	p.readCommandName()
+
+	endName, err := p.parseRawGroup()
+	if err != nil {
+		return mathNode{}, err
+	}
+	if endName != name {
+		return mathNode{}, fmt.Errorf("`\\begin{%s}` ended by `\\end{%s}`", name, endName)
+	}
+
+	if open == "" && closing == "" {
+		return mathNode{markup: sb.String()}, nil
+	}
+
+	return mathNode{markup: fence(open, closing, sb.String())}, nil
+}
+
+func fence(open, closing, inner string) string {
+	var sb strings.Builder
+	sb.WriteString("<mrow>")
+	if open != "" {
+		sb.WriteString(`<mo fence="true" stretchy="true">` + open + "</mo>")
+	}
+	sb.WriteString(inner)
+	if closing != "" {
+		sb.WriteString(`<mo fence="true" stretchy="true">` + closing + "</mo>")
+	}
+	sb.WriteString("</mrow>")
+	return sb.String()
+}
+
+func mrow(nodes []mathNode) string {
+	if len(nodes) == 1 {
+		return nodes[0].markup
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<mrow>")
+	for _, n := range nodes {
+		sb.WriteString(n.markup)
+	}
+	sb.WriteString("</mrow>")
+	return sb.String()
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
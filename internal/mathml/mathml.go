@@ -0,0 +1,353 @@
+// This goldmark extension converts LaTeX math into MathML at build
+// time, so equations can be displayed without any client-side
+// JavaScript in both web browsers and EPUB readers.
+//
+// Inline math is written between single dollar signs, such as
+// `$e^{i\pi} + 1 = 0$`. The opening `$` must be followed by a
+// non-space character and the closing `$` must be preceded by a
+// non-space character and not be followed by a digit. Inline math
+// must be written on a single line and cannot contain an unescaped
+// `$`, so prices like "$5 and $10" are left alone.
+//
+// Display math is written between double dollar signs, either inside
+// of a paragraph or as a block:
+//
+//	$$
+//	\int_0^1 x^2 \, dx = \frac{1}{3}
+//	$$
+//
+// Display math that cannot be converted (e.g. because of an
+// unsupported macro) is reported by Errors along with its line number.
+// Inline math that cannot be converted is left as text, as a pair of
+// dollar signs in prose is not always math.
+package mathml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	errorsKey = parser.NewContextKey()
+
+	displayDelimiter = []byte("$$")
+)
+
+// Error describes a math expression that could not be converted at a
+// line in the markdown source.
+type Error struct {
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: math: %v", e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns all math errors found while parsing, or nil if there
+// were none.
+func Errors(pc parser.Context) error {
+	v := pc.Get(errorsKey)
+	if v == nil {
+		return nil
+	}
+
+	return errors.Join(v.([]error)...)
+}
+
+func addError(pc parser.Context, err error) {
+	var errs []error
+	if v := pc.Get(errorsKey); v != nil {
+		errs = v.([]error)
+	}
+
+	pc.Set(errorsKey, append(errs, err))
+}
+
+func lineNumber(source []byte, segment text.Segment) int {
+	return bytes.Count(source[:segment.Start], []byte{'\n'}) + 1
+}
+
+// KindInline is a NodeKind of the Inline node.
+var KindInline = gast.NewNodeKind("MathInline")
+
+// Inline is a math expression written inside of a block of text.
+type Inline struct {
+	gast.BaseInline
+
+	// MathML is the converted <math> element.
+	MathML string
+}
+
+// Kind implements Node.Kind.
+func (n *Inline) Kind() gast.NodeKind {
+	return KindInline
+}
+
+// Dump implements Node.Dump.
+func (n *Inline) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"MathML": n.MathML}, nil)
+}
+
+// KindBlock is a NodeKind of the Block node.
+var KindBlock = gast.NewNodeKind("MathBlock")
+
+// Block is a display math expression written as a block.
+type Block struct {
+	gast.BaseBlock
+
+	// MathML is the converted <math> element.
+	MathML string
+
+	line   int
+	tex    strings.Builder
+	closed bool
+}
+
+// Kind implements Node.Kind.
+func (n *Block) Kind() gast.NodeKind {
+	return KindBlock
+}
+
+// Dump implements Node.Dump.
+func (n *Block) Dump(source []byte, level int) {
+	gast.DumpHelper(n, source, level, map[string]string{"MathML": n.MathML}, nil)
+}
+
+type inlineParser struct {
+}
+
+var defaultInlineParser = &inlineParser{}
+
+// NewInlineParser returns an InlineParser that parses `$...$` and
+// `$$...$$` math inside of a block of text.
+func NewInlineParser() parser.InlineParser {
+	return defaultInlineParser
+}
+
+func (s *inlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// findClosing returns the index of the closing delimiter in line, or
+// -1 if there is none. Inline math ends at the first unescaped `$`, so
+// it is not closed if that `$` cannot close it.
+func findClosing(line []byte, start int, display bool) int {
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '$':
+			if display {
+				if i+1 < len(line) && line[i+1] == '$' {
+					return i
+				}
+				continue
+			}
+
+			// `$$` can never close inline math
+			if i+1 < len(line) && line[i+1] == '$' {
+				return -1
+			}
+
+			if util.IsSpace(line[i-1]) || (i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9') {
+				return -1
+			}
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *inlineParser) Parse(parent gast.Node, block text.Reader, pc parser.Context) gast.Node {
+	line, segment := block.PeekLine()
+
+	display := bytes.HasPrefix(line, displayDelimiter)
+	delimiterLength := 1
+	if display {
+		delimiterLength = 2
+	}
+
+	if len(line) <= delimiterLength || (!display && util.IsSpace(line[delimiterLength])) {
+		return nil
+	}
+
+	end := findClosing(line, delimiterLength+1, display)
+	if end < 0 {
+		return nil
+	}
+
+	tex := string(line[delimiterLength:end])
+	mathML, err := Convert(tex, display)
+	if err != nil {
+		if !display {
+			return nil
+		}
+		addError(pc, &Error{Line: lineNumber(block.Source(), segment), Err: err})
+		return nil
+	}
+
+	block.Advance(end + delimiterLength)
+	return &Inline{MathML: mathML}
+}
+
+type blockParser struct {
+}
+
+var defaultBlockParser = &blockParser{}
+
+// NewBlockParser returns a BlockParser that parses `$$` display math
+// blocks.
+func NewBlockParser() parser.BlockParser {
+	return defaultBlockParser
+}
+
+func (b *blockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (b *blockParser) Open(parent gast.Node, reader text.Reader, pc parser.Context) (gast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w > 3 || !bytes.HasPrefix(line[pos:], displayDelimiter) {
+		return nil, parser.NoChildren
+	}
+
+	rest := util.TrimRightSpace(line[pos+len(displayDelimiter):])
+	node := &Block{line: lineNumber(reader.Source(), segment)}
+
+	if i := bytes.Index(rest, displayDelimiter); i >= 0 {
+		// Single line block: $$ ... $$
+		if !util.IsBlank(rest[i+len(displayDelimiter):]) {
+			return nil, parser.NoChildren
+		}
+		node.tex.Write(rest[:i])
+		node.closed = true
+	} else {
+		node.tex.Write(rest)
+	}
+
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (b *blockParser) Continue(node gast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*Block)
+	if n.closed {
+		return parser.Close
+	}
+
+	line, segment := reader.PeekLine()
+	trimmed := util.TrimRightSpace(line)
+	if bytes.HasSuffix(trimmed, displayDelimiter) {
+		n.tex.WriteByte('\n')
+		n.tex.Write(trimmed[:len(trimmed)-len(displayDelimiter)])
+		n.closed = true
+		reader.Advance(segment.Len() - 1)
+		return parser.Close
+	}
+
+	n.tex.WriteByte('\n')
+	n.tex.Write(trimmed)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (b *blockParser) Close(node gast.Node, reader text.Reader, pc parser.Context) {
+	n := node.(*Block)
+	if !n.closed {
+		addError(pc, &Error{Line: n.line, Err: errors.New("missing closing `$$`")})
+		return
+	}
+
+	mathML, err := Convert(strings.TrimSpace(n.tex.String()), true)
+	if err != nil {
+		addError(pc, &Error{Line: n.line, Err: err})
+		return
+	}
+	n.MathML = mathML
+}
+
+func (b *blockParser) CanInterruptParagraph() bool {
+	return false
+}
+
+func (b *blockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// HTMLRenderer is a renderer.NodeRenderer implementation that renders
+// math nodes. The same MathML output is used for both HTML and XHTML.
+type HTMLRenderer struct {
+	html.Config
+}
+
+// NewHTMLRenderer returns a new HTMLRenderer.
+func NewHTMLRenderer(opts ...html.Option) renderer.NodeRenderer {
+	r := &HTMLRenderer{
+		Config: html.NewConfig(),
+	}
+	for _, opt := range opts {
+		opt.SetHTMLOption(&r.Config)
+	}
+	return r
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.RegisterFuncs.
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindInline, r.renderInline)
+	reg.Register(KindBlock, r.renderBlock)
+}
+
+func (r *HTMLRenderer) renderInline(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(node.(*Inline).MathML)
+	}
+
+	return gast.WalkSkipChildren, nil
+}
+
+func (r *HTMLRenderer) renderBlock(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(node.(*Block).MathML)
+		_ = w.WriteByte('\n')
+	}
+
+	return gast.WalkSkipChildren, nil
+}
+
+type mathML struct {
+}
+
+// MathML is a goldmark.Extender implementation.
+var MathML = &mathML{}
+
+// Extend implements goldmark.Extender.
+func (e *mathML) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(NewBlockParser(), 150),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(NewInlineParser(), 150),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(NewHTMLRenderer(), 500),
+	))
+}
@@ -0,0 +1,73 @@
+package mathml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+func convert(t *testing.T, source string) (string, error) {
+	t.Helper()
+
+	md := goldmark.New(goldmark.WithExtensions(MathML))
+	pc := parser.NewContext()
+	document := md.Parser().Parse(text.NewReader([]byte(source)), parser.WithContext(pc))
+	if err := Errors(pc); err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := md.Renderer().Render(&buffer, []byte(source), document); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.String(), nil
+}
+
+func TestInlineMath(t *testing.T) {
+	tests := []struct {
+		source string
+		math   int
+		text   []string
+	}{
+		{"Price $5 and $10. Inline $e^{i\\pi} + 1 = 0$", 1, []string{"Price $5 and $10. Inline "}},
+		{"It costs $5 and $10.", 0, []string{"It costs $5 and $10."}},
+		{"Between $a$ and $b$.", 2, []string{"Between ", " and ", "."}},
+		{"Not closed $a $ here", 0, []string{"Not closed $a $ here"}},
+		{"Unknown $\\notamacro{x}$ here", 0, []string{"Unknown $\\notamacro{x}$ here"}},
+		{"Escaped $a\\$b$ dollar", 1, []string{"Escaped ", " dollar"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			got, err := convert(t, test.source)
+			if err != nil {
+				t.Fatalf("convert: %v", err)
+			}
+
+			if n := strings.Count(got, "<math"); n != test.math {
+				t.Errorf("got %d <math> elements, want %d in %q", n, test.math, got)
+			}
+
+			for _, s := range test.text {
+				if !strings.Contains(got, s) {
+					t.Errorf("output %q does not contain %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestDisplayMathError(t *testing.T) {
+	if _, err := convert(t, "Text\n\n$$\n\\notamacro{x}\n$$\n"); err == nil {
+		t.Error("display math with an unknown macro did not fail")
+	}
+
+	if _, err := convert(t, "Text\n\n$$\nx^2\n"); err == nil {
+		t.Error("unclosed display math did not fail")
+	}
+}
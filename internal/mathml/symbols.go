@@ -0,0 +1,258 @@
+package mathml
+
+var (
+	// Lowercase Greek letters and other italic identifiers.
+	identifiers = map[string]string{
+		"alpha":      "&#x3B1;",
+		"beta":       "&#x3B2;",
+		"gamma":      "&#x3B3;",
+		"delta":      "&#x3B4;",
+		"epsilon":    "&#x3F5;",
+		"varepsilon": "&#x3B5;",
+		"zeta":       "&#x3B6;",
+		"eta":        "&#x3B7;",
+		"theta":      "&#x3B8;",
+		"vartheta":   "&#x3D1;",
+		"iota":       "&#x3B9;",
+		"kappa":      "&#x3BA;",
+		"lambda":     "&#x3BB;",
+		"mu":         "&#x3BC;",
+		"nu":         "&#x3BD;",
+		"xi":         "&#x3BE;",
+		"pi":         "&#x3C0;",
+		"varpi":      "&#x3D6;",
+		"rho":        "&#x3C1;",
+		"varrho":     "&#x3F1;",
+		"sigma":      "&#x3C3;",
+		"varsigma":   "&#x3C2;",
+		"tau":        "&#x3C4;",
+		"upsilon":    "&#x3C5;",
+		"phi":        "&#x3D5;",
+		"varphi":     "&#x3C6;",
+		"chi":        "&#x3C7;",
+		"psi":        "&#x3C8;",
+		"omega":      "&#x3C9;",
+		"ell":        "&#x2113;",
+		"hbar":       "&#x210F;",
+		"imath":      "&#x131;",
+		"jmath":      "&#x237;",
+	}
+
+	// Uppercase Greek letters and other identifiers that are upright.
+	uprightIdentifiers = map[string]string{
+		"Gamma":    "&#x393;",
+		"Delta":    "&#x394;",
+		"Theta":    "&#x398;",
+		"Lambda":   "&#x39B;",
+		"Xi":       "&#x39E;",
+		"Pi":       "&#x3A0;",
+		"Sigma":    "&#x3A3;",
+		"Upsilon":  "&#x3A5;",
+		"Phi":      "&#x3A6;",
+		"Psi":      "&#x3A8;",
+		"Omega":    "&#x3A9;",
+		"infty":    "&#x221E;",
+		"emptyset": "&#x2205;",
+		"aleph":    "&#x2135;",
+		"Re":       "&#x211C;",
+		"Im":       "&#x2111;",
+		"partial":  "&#x2202;",
+		"nabla":    "&#x2207;",
+	}
+
+	operators = map[string]string{
+		"{":               "{",
+		"}":               "}",
+		"|":               "&#x2016;",
+		"%":               "%",
+		"$":               "$",
+		"#":               "#",
+		"&":               "&amp;",
+		"_":               "_",
+		"times":           "&#xD7;",
+		"cdot":            "&#x22C5;",
+		"div":             "&#xF7;",
+		"pm":              "&#xB1;",
+		"mp":              "&#x2213;",
+		"ast":             "&#x2217;",
+		"star":            "&#x22C6;",
+		"circ":            "&#x2218;",
+		"bullet":          "&#x2219;",
+		"oplus":           "&#x2295;",
+		"otimes":          "&#x2297;",
+		"wedge":           "&#x2227;",
+		"land":            "&#x2227;",
+		"vee":             "&#x2228;",
+		"lor":             "&#x2228;",
+		"neg":             "&#xAC;",
+		"lnot":            "&#xAC;",
+		"cup":             "&#x222A;",
+		"cap":             "&#x2229;",
+		"setminus":        "&#x2216;",
+		"eq":              "=",
+		"ne":              "&#x2260;",
+		"neq":             "&#x2260;",
+		"le":              "&#x2264;",
+		"leq":             "&#x2264;",
+		"ge":              "&#x2265;",
+		"geq":             "&#x2265;",
+		"ll":              "&#x226A;",
+		"gg":              "&#x226B;",
+		"lt":              "&lt;",
+		"gt":              "&gt;",
+		"approx":          "&#x2248;",
+		"equiv":           "&#x2261;",
+		"sim":             "&#x223C;",
+		"simeq":           "&#x2243;",
+		"cong":            "&#x2245;",
+		"propto":          "&#x221D;",
+		"in":              "&#x2208;",
+		"notin":           "&#x2209;",
+		"ni":              "&#x220B;",
+		"subset":          "&#x2282;",
+		"supset":          "&#x2283;",
+		"subseteq":        "&#x2286;",
+		"supseteq":        "&#x2287;",
+		"forall":          "&#x2200;",
+		"exists":          "&#x2203;",
+		"nexists":         "&#x2204;",
+		"to":              "&#x2192;",
+		"rightarrow":      "&#x2192;",
+		"leftarrow":       "&#x2190;",
+		"gets":            "&#x2190;",
+		"leftrightarrow":  "&#x2194;",
+		"Rightarrow":      "&#x21D2;",
+		"Leftarrow":       "&#x21D0;",
+		"Leftrightarrow":  "&#x21D4;",
+		"implies":         "&#x27F9;",
+		"iff":             "&#x27FA;",
+		"mapsto":          "&#x21A6;",
+		"uparrow":         "&#x2191;",
+		"downarrow":       "&#x2193;",
+		"longrightarrow":  "&#x27F6;",
+		"longleftarrow":   "&#x27F5;",
+		"mid":             "|",
+		"parallel":        "&#x2225;",
+		"perp":            "&#x22A5;",
+		"angle":           "&#x2220;",
+		"triangle":        "&#x25B3;",
+		"ldots":           "&#x2026;",
+		"dots":            "&#x2026;",
+		"cdots":           "&#x22EF;",
+		"vdots":           "&#x22EE;",
+		"ddots":           "&#x22F1;",
+		"prime":           "&#x2032;",
+		"colon":           ":",
+		"langle":          "&#x27E8;",
+		"rangle":          "&#x27E9;",
+		"lfloor":          "&#x230A;",
+		"rfloor":          "&#x230B;",
+		"lceil":           "&#x2308;",
+		"rceil":           "&#x2309;",
+		"vert":            "|",
+		"Vert":            "&#x2016;",
+		"therefore":       "&#x2234;",
+		"because":         "&#x2235;",
+		"degree":          "&#xB0;",
+		"top":             "&#x22A4;",
+		"bot":             "&#x22A5;",
+		"vdash":           "&#x22A2;",
+		"models":          "&#x22A8;",
+		"leftrightarrows": "&#x21C6;",
+	}
+
+	// Large operators with limits placed above/below in display mode.
+	largeOperators = map[string]string{
+		"sum":      "&#x2211;",
+		"prod":     "&#x220F;",
+		"coprod":   "&#x2210;",
+		"bigcup":   "&#x22C3;",
+		"bigcap":   "&#x22C2;",
+		"bigoplus": "&#x2A01;",
+		"bigwedge": "&#x22C0;",
+		"bigvee":   "&#x22C1;",
+	}
+
+	// Integrals always have limits placed to the side.
+	integrals = map[string]string{
+		"int":   "&#x222B;",
+		"iint":  "&#x222C;",
+		"iiint": "&#x222D;",
+		"oint":  "&#x222E;",
+	}
+
+	functions = map[string]struct{}{
+		"sin": {}, "cos": {}, "tan": {}, "cot": {}, "sec": {}, "csc": {},
+		"arcsin": {}, "arccos": {}, "arctan": {},
+		"sinh": {}, "cosh": {}, "tanh": {},
+		"log": {}, "ln": {}, "lg": {}, "exp": {},
+		"deg": {}, "dim": {}, "ker": {}, "arg": {}, "hom": {},
+		"gcd": {}, "Pr": {},
+	}
+
+	// Functions with limits placed below in display mode.
+	limitFunctions = map[string]struct{}{
+		"lim": {}, "limsup": {}, "liminf": {},
+		"max": {}, "min": {}, "sup": {}, "inf": {},
+		"det": {}, "argmax": {}, "argmin": {},
+	}
+
+	spaces = map[string]string{
+		",":         "0.167em",
+		"thinspace": "0.167em",
+		":":         "0.222em",
+		">":         "0.222em",
+		";":         "0.278em",
+		" ":         "0.278em",
+		"quad":      "1em",
+		"qquad":     "2em",
+		"!":         "-0.167em",
+	}
+
+	accents = map[string]string{
+		"hat":       "^",
+		"widehat":   "^",
+		"bar":       "&#xAF;",
+		"vec":       "&#x2192;",
+		"dot":       "&#x2D9;",
+		"ddot":      "&#xA8;",
+		"tilde":     "~",
+		"widetilde": "~",
+		"check":     "&#x2C7;",
+		"breve":     "&#x2D8;",
+		"acute":     "&#xB4;",
+		"grave":     "`",
+	}
+
+	// Font commands and their MathML mathvariant.
+	fonts = map[string]string{
+		"mathrm":   "normal",
+		"mathbf":   "bold",
+		"mathit":   "italic",
+		"mathbb":   "double-struck",
+		"mathcal":  "script",
+		"mathscr":  "script",
+		"mathfrak": "fraktur",
+		"mathsf":   "sans-serif",
+		"mathtt":   "monospace",
+	}
+
+	// Delimiters that can be used with \left and \right.
+	delimiters = map[string]string{
+		"{":      "{",
+		"}":      "}",
+		"|":      "&#x2016;",
+		"langle": "&#x27E8;",
+		"rangle": "&#x27E9;",
+		"lfloor": "&#x230A;",
+		"rfloor": "&#x230B;",
+		"lceil":  "&#x2308;",
+		"rceil":  "&#x2309;",
+		"vert":   "|",
+		"Vert":   "&#x2016;",
+		"lvert":  "|",
+		"rvert":  "|",
+		"lVert":  "&#x2016;",
+		"rVert":  "&#x2016;",
+	}
+)
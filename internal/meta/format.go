@@ -0,0 +1,82 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	yaml "github.com/goccy/go-yaml"
+)
+
+// Format is the language of a metadata block or configuration file.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+	FormatJSON Format = "json"
+)
+
+// Returns the format of a configuration file by its extension (e.g.
+// `.yml`), or an empty string if it is not supported.
+func FormatFromExtension(ext string) Format {
+	switch ext {
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	case ".json":
+		return FormatJSON
+	}
+
+	return ""
+}
+
+// Returns the format of a metadata block starting with line, or an
+// empty string if line does not start one. YAML is between `---`
+// lines, TOML is between `+++` lines and JSON is an object between a
+// `{` line and a `}` line.
+func FormatFromDelimiter(line []byte) Format {
+	line = bytes.TrimSpace(line)
+	switch {
+	case isDelimiter(line, '-'):
+		return FormatYAML
+	case isDelimiter(line, '+'):
+		return FormatTOML
+	case string(line) == "{":
+		return FormatJSON
+	}
+
+	return ""
+}
+
+// A line of at least 3 c characters.
+func isDelimiter(line []byte, c byte) bool {
+	if len(line) < 3 {
+		return false
+	}
+
+	for _, b := range line {
+		if b != c {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Unmarshal data of the format into v.
+func Unmarshal(format Format, data []byte, v *map[string]any) error {
+	switch format {
+	case FormatYAML:
+		return yaml.Unmarshal(data, v)
+	case FormatTOML:
+		_, err := toml.Decode(string(data), v)
+		return err
+	case FormatJSON:
+		return json.Unmarshal(data, v)
+	}
+
+	return fmt.Errorf("unsupported metadata format `%v`", format)
+}
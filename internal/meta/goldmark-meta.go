@@ -1,5 +1,5 @@
-// This extension parses YAML metadata blocks and store metadata to a
-// parser.Context.
+// This extension parses YAML, TOML and JSON metadata blocks and store
+// metadata to a parser.Context.
 //
 // Credit: https://github.com/yuin/goldmark-meta
 // License: MIT
@@ -30,11 +30,14 @@
 // Changes made to original:
 //   - uses "github.com/goccy/go-yaml" as a dependency instead of
 //     "gopkg.in/yaml.v2"
+//   - parses TOML (`+++`) and JSON (`{`) metadata blocks as well as
+//     YAML (`---`), see Format
 package meta
 
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/yuin/goldmark"
 	gast "github.com/yuin/goldmark/ast"
@@ -47,14 +50,18 @@ import (
 )
 
 type data struct {
-	Map   map[string]interface{}
-	Items yaml.MapSlice
-	Error error
-	Node  gast.Node
+	Format Format
+	Map    map[string]interface{}
+	Items  yaml.MapSlice
+	Error  error
+	Node   gast.Node
 }
 
 var contextKey = parser.NewContextKey()
 
+// Format of the metadata block being parsed.
+var formatKey = parser.NewContextKey()
+
 // Option interface sets options for this extension.
 type Option interface {
 	metaOption()
@@ -84,6 +91,17 @@ func TryGet(pc parser.Context) (map[string]interface{}, error) {
 	return d.Map, nil
 }
 
+// GetFormat returns the format of the metadata, or an empty string if
+// there is none.
+func GetFormat(pc parser.Context) Format {
+	v := pc.Get(contextKey)
+	if v == nil {
+		return ""
+	}
+	d := v.(*data)
+	return d.Format
+}
+
 // GetItems returns a YAML metadata.
 // GetItems preserves defined key order.
 func GetItems(pc parser.Context) yaml.MapSlice {
@@ -120,18 +138,8 @@ func NewParser() parser.BlockParser {
 	return defaultParser
 }
 
-func isSeparator(line []byte) bool {
-	line = util.TrimRightSpace(util.TrimLeftSpace(line))
-	for i := 0; i < len(line); i++ {
-		if line[i] != '-' {
-			return false
-		}
-	}
-	return true
-}
-
 func (b *metaParser) Trigger() []byte {
-	return []byte{'-'}
+	return []byte{'-', '+', '{'}
 }
 
 func (b *metaParser) Open(parent gast.Node, reader text.Reader, pc parser.Context) (gast.Node, parser.State) {
@@ -139,16 +147,34 @@ func (b *metaParser) Open(parent gast.Node, reader text.Reader, pc parser.Contex
 	if linenum != 0 {
 		return nil, parser.NoChildren
 	}
-	line, _ := reader.PeekLine()
-	if isSeparator(line) {
-		return gast.NewTextBlock(), parser.NoChildren
+	line, segment := reader.PeekLine()
+	format := FormatFromDelimiter(line)
+	if format == "" {
+		return nil, parser.NoChildren
+	}
+	pc.Set(formatKey, format)
+
+	node := gast.NewTextBlock()
+	if format == FormatJSON {
+		// The braces are part of the JSON object
+		node.Lines().Append(segment)
 	}
-	return nil, parser.NoChildren
+	return node, parser.NoChildren
 }
 
 func (b *metaParser) Continue(node gast.Node, reader text.Reader, pc parser.Context) parser.State {
 	line, segment := reader.PeekLine()
-	if isSeparator(line) && !util.IsBlank(line) {
+	format := pc.Get(formatKey).(Format)
+	if format == FormatJSON {
+		node.Lines().Append(segment)
+		if string(util.TrimRightSpace(line)) == "}" {
+			reader.Advance(segment.Len())
+			return parser.Close
+		}
+		return parser.Continue | parser.NoChildren
+	}
+
+	if FormatFromDelimiter(line) == format {
 		reader.Advance(segment.Len())
 		return parser.Close
 	}
@@ -165,17 +191,31 @@ func (b *metaParser) Close(node gast.Node, reader text.Reader, pc parser.Context
 	}
 	d := &data{}
 	d.Node = node
+	d.Format = pc.Get(formatKey).(Format)
 	meta := map[string]interface{}{}
-	if err := yaml.Unmarshal(buf.Bytes(), &meta); err != nil {
+	if err := Unmarshal(d.Format, buf.Bytes(), &meta); err != nil {
 		d.Error = err
 	} else {
 		d.Map = meta
 	}
 
 	metaMapSlice := yaml.MapSlice{}
-	if err := yaml.Unmarshal(buf.Bytes(), &metaMapSlice); err != nil {
-		d.Error = err
-	} else {
+	if d.Format == FormatYAML {
+		if err := yaml.Unmarshal(buf.Bytes(), &metaMapSlice); err != nil {
+			d.Error = err
+		} else {
+			d.Items = metaMapSlice
+		}
+	} else if d.Error == nil {
+		// Only YAML preserves the order of keys
+		keys := make([]string, 0, len(meta))
+		for k := range meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			metaMapSlice = append(metaMapSlice, yaml.MapItem{Key: k, Value: meta[k]})
+		}
 		d.Items = metaMapSlice
 	}
 
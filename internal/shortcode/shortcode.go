@@ -0,0 +1,526 @@
+// This goldmark extension adds shortcodes, which are reusable
+// components that authors can embed inside of markdown without writing
+// raw HTML, such as:
+//
+//	{{< figure src="map.webp" caption="The known world" >}}
+//
+// A shortcode is rendered by executing the template with the same
+// name (e.g. `figure.html`) from the templates given to the parser
+// context with SetTemplates. When rendering XHTML, a template with
+// the `.xhtml` extension (e.g. `figure.xhtml`) is preferred if it
+// exists.
+//
+// Shortcodes written on a line of their own are parsed as blocks,
+// while shortcodes written within a paragraph are parsed as inline
+// elements.
+package shortcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	templatesKey = parser.NewContextKey()
+	errorsKey    = parser.NewContextKey()
+
+	openDelimiter  = []byte("{{<")
+	closeDelimiter = []byte(">}}")
+
+	// An opening delimiter without a closing one is left as text, as
+	// it may be prose rather than a shortcode.
+	errUnterminated = errors.New("missing closing `>}}`")
+)
+
+// Data is passed to a shortcode template when it is executed.
+type Data struct {
+	// Name of the shortcode (e.g. `figure`).
+	Name string
+
+	// Params contains the key="value" pairs given to the shortcode.
+	Params map[string]string
+
+	// Line is the line number of the shortcode in the markdown
+	// source.
+	Line int
+
+	// IsXHTML is true when the shortcode is being rendered for an
+	// XHTML document (e.g. EPUB).
+	IsXHTML bool
+}
+
+// Get returns the value of the parameter named key, or an empty
+// string if it does not exist.
+func (d Data) Get(key string) string {
+	return d.Params[key]
+}
+
+// Error describes a problem with a shortcode found at a line in the
+// markdown source.
+type Error struct {
+	Line int
+	Name string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("line %d: shortcode `%s`: %v", e.Line, e.Name, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ParseFS parses all `*.html` and `*.xhtml` shortcode templates found
+// in dir of fsys. If dir does not exist, then it returns an empty set
+// of templates.
+func ParseFS(fsys fs.FS, dir string) (*template.Template, error) {
+	templates := template.New("shortcodes")
+
+	items, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return templates, nil
+		}
+
+		return nil, err
+	}
+
+	for _, item := range items {
+		ext := path.Ext(item.Name())
+		if item.IsDir() || (ext != ".html" && ext != ".xhtml") {
+			continue
+		}
+
+		name := path.Join(dir, item.Name())
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := templates.New(item.Name()).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("failed to parse shortcode template `%v`. %w", item.Name(), err)
+		}
+	}
+
+	return templates, nil
+}
+
+// ParseDir parses all shortcode templates found in dir of the OS
+// filesystem (see ParseFS).
+func ParseDir(dir string) (*template.Template, error) {
+	return ParseFS(os.DirFS(dir), ".")
+}
+
+// SetTemplates sets the shortcode templates that will be used by the
+// parser for the given context.
+func SetTemplates(pc parser.Context, templates *template.Template) {
+	pc.Set(templatesKey, templates)
+}
+
+// Errors returns all shortcode errors found while parsing, or nil if
+// there were none.
+func Errors(pc parser.Context) error {
+	v := pc.Get(errorsKey)
+	if v == nil {
+		return nil
+	}
+
+	return errors.Join(v.([]error)...)
+}
+
+func addError(pc parser.Context, err error) {
+	var errs []error
+	if v := pc.Get(errorsKey); v != nil {
+		errs = v.([]error)
+	}
+
+	pc.Set(errorsKey, append(errs, err))
+}
+
+// Invocation holds the information needed to render a shortcode.
+type Invocation struct {
+	Name      string
+	Params    map[string]string
+	Line      int
+	Templates *template.Template
+}
+
+func (s *Invocation) dump(source []byte, level int, n gast.Node) {
+	kv := map[string]string{
+		"Name": s.Name,
+		"Line": fmt.Sprint(s.Line),
+	}
+	for k, v := range s.Params {
+		kv["Params."+k] = v
+	}
+
+	gast.DumpHelper(n, source, level, kv, nil)
+}
+
+// KindBlock is a NodeKind of the Block node.
+var KindBlock = gast.NewNodeKind("ShortcodeBlock")
+
+// Block is a shortcode that is written on a line of its own.
+type Block struct {
+	gast.BaseBlock
+	Invocation
+}
+
+// Kind implements Node.Kind.
+func (n *Block) Kind() gast.NodeKind {
+	return KindBlock
+}
+
+// Dump implements Node.Dump.
+func (n *Block) Dump(source []byte, level int) {
+	n.dump(source, level, n)
+}
+
+// KindInline is a NodeKind of the Inline node.
+var KindInline = gast.NewNodeKind("ShortcodeInline")
+
+// Inline is a shortcode that is written inside of a block of text.
+type Inline struct {
+	gast.BaseInline
+	Invocation
+}
+
+// Kind implements Node.Kind.
+func (n *Inline) Kind() gast.NodeKind {
+	return KindInline
+}
+
+// Dump implements Node.Dump.
+func (n *Inline) Dump(source []byte, level int) {
+	n.dump(source, level, n)
+}
+
+// parse reads a shortcode at the start of line, returning the
+// number of bytes read.
+func parse(line []byte) (name string, params map[string]string, n int, err error) {
+	if !bytes.HasPrefix(line, openDelimiter) {
+		return "", nil, 0, errors.New("missing `{{<`")
+	}
+
+	end := bytes.Index(line, closeDelimiter)
+	if end < 0 {
+		return "", nil, 0, errUnterminated
+	}
+
+	body := line[len(openDelimiter):end]
+	pos := skipSpaces(body, 0)
+
+	start := pos
+	for pos < len(body) && isNameChar(body[pos]) {
+		pos++
+	}
+	name = string(body[start:pos])
+	if name == "" {
+		return "", nil, 0, errors.New("missing shortcode name")
+	}
+
+	params = map[string]string{}
+	for {
+		pos = skipSpaces(body, pos)
+		if pos >= len(body) {
+			break
+		}
+
+		start = pos
+		for pos < len(body) && isNameChar(body[pos]) {
+			pos++
+		}
+		key := string(body[start:pos])
+		if key == "" || pos >= len(body) || body[pos] != '=' {
+			return name, nil, 0, fmt.Errorf("invalid parameter near `%s`, expected key=\"value\"", body[start:])
+		}
+		pos++
+
+		var value []byte
+		if pos < len(body) && body[pos] == '"' {
+			pos++
+			for pos < len(body) && body[pos] != '"' {
+				if body[pos] == '\\' && pos+1 < len(body) {
+					pos++
+				}
+				value = append(value, body[pos])
+				pos++
+			}
+			if pos >= len(body) {
+				return name, nil, 0, fmt.Errorf("unterminated quoted value for parameter `%s`", key)
+			}
+			pos++
+		} else {
+			start = pos
+			for pos < len(body) && !util.IsSpace(body[pos]) {
+				pos++
+			}
+			value = body[start:pos]
+		}
+
+		if _, ok := params[key]; ok {
+			return name, nil, 0, fmt.Errorf("duplicate parameter `%s`", key)
+		}
+		params[key] = string(value)
+	}
+
+	return name, params, end + len(closeDelimiter), nil
+}
+
+func skipSpaces(b []byte, pos int) int {
+	for pos < len(b) && util.IsSpace(b[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || util.IsAlphaNumeric(c)
+}
+
+func lineNumber(source []byte, segment text.Segment) int {
+	return bytes.Count(source[:segment.Start], []byte{'\n'}) + 1
+}
+
+// Returns the shortcode templates of the parser context, or nil if
+// there are none.
+func contextTemplates(pc parser.Context) *template.Template {
+	if v := pc.Get(templatesKey); v != nil {
+		return v.(*template.Template)
+	}
+
+	return nil
+}
+
+// newInvocation parses a shortcode at the start of line and checks
+// that a template exists for it. Errors are recorded in the parser
+// context, except for a missing closing delimiter, which leaves the
+// line as text.
+func newInvocation(line []byte, lineNum int, pc parser.Context) (Invocation, int, bool) {
+	name, params, n, err := parse(line)
+	if errors.Is(err, errUnterminated) {
+		return Invocation{}, 0, false
+	} else if err != nil {
+		addError(pc, &Error{Line: lineNum, Name: name, Err: err})
+		return Invocation{}, 0, false
+	}
+
+	templates := contextTemplates(pc)
+	if templates == nil || templates.Lookup(name+".html") == nil {
+		addError(pc, &Error{Line: lineNum, Name: name, Err: fmt.Errorf("unknown shortcode, missing template `shortcodes/%s.html`", name)})
+		return Invocation{}, 0, false
+	}
+
+	return Invocation{
+		Name:      name,
+		Params:    params,
+		Line:      lineNum,
+		Templates: templates,
+	}, n, true
+}
+
+type blockParser struct {
+}
+
+var defaultBlockParser = &blockParser{}
+
+// NewBlockParser returns a BlockParser that parses shortcodes written
+// on a line of their own.
+func NewBlockParser() parser.BlockParser {
+	return defaultBlockParser
+}
+
+func (b *blockParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (b *blockParser) Open(parent gast.Node, reader text.Reader, pc parser.Context) (gast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w > 3 || !bytes.HasPrefix(line[pos:], openDelimiter) {
+		return nil, parser.NoChildren
+	}
+
+	// Only take the line if it contains a single valid shortcode with
+	// a template. Otherwise, leave it to the inline parser, which
+	// reports any errors, so that they are only reported once.
+	name, _, n, err := parse(line[pos:])
+	if err != nil || !util.IsBlank(line[pos+n:]) {
+		return nil, parser.NoChildren
+	}
+
+	if templates := contextTemplates(pc); templates == nil || templates.Lookup(name+".html") == nil {
+		return nil, parser.NoChildren
+	}
+
+	invocation, _, ok := newInvocation(line[pos:], lineNumber(reader.Source(), segment), pc)
+	if !ok {
+		return nil, parser.NoChildren
+	}
+
+	reader.AdvanceToEOL()
+	return &Block{Invocation: invocation}, parser.NoChildren
+}
+
+func (b *blockParser) Continue(node gast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (b *blockParser) Close(node gast.Node, reader text.Reader, pc parser.Context) {
+	// nothing to do
+}
+
+func (b *blockParser) CanInterruptParagraph() bool {
+	return false
+}
+
+func (b *blockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type inlineParser struct {
+}
+
+var defaultInlineParser = &inlineParser{}
+
+// NewInlineParser returns an InlineParser that parses shortcodes
+// written inside of a block of text.
+func NewInlineParser() parser.InlineParser {
+	return defaultInlineParser
+}
+
+func (s *inlineParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (s *inlineParser) Parse(parent gast.Node, block text.Reader, pc parser.Context) gast.Node {
+	line, segment := block.PeekLine()
+	if !bytes.HasPrefix(line, openDelimiter) {
+		return nil
+	}
+
+	invocation, n, ok := newInvocation(line, lineNumber(block.Source(), segment), pc)
+	if !ok {
+		return nil
+	}
+
+	block.Advance(n)
+	return &Inline{Invocation: invocation}
+}
+
+// HTMLRenderer is a renderer.NodeRenderer implementation that renders
+// shortcodes.
+type HTMLRenderer struct {
+	html.Config
+}
+
+// NewHTMLRenderer returns a new HTMLRenderer.
+func NewHTMLRenderer(opts ...html.Option) renderer.NodeRenderer {
+	r := &HTMLRenderer{
+		Config: html.NewConfig(),
+	}
+	for _, opt := range opts {
+		opt.SetHTMLOption(&r.Config)
+	}
+	return r
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.RegisterFuncs.
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindBlock, r.renderBlock)
+	reg.Register(KindInline, r.renderInline)
+}
+
+func (r *HTMLRenderer) renderBlock(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	if !entering {
+		return gast.WalkContinue, nil
+	}
+
+	if err := r.render(w, &node.(*Block).Invocation, false); err != nil {
+		return gast.WalkStop, err
+	}
+
+	return gast.WalkContinue, nil
+}
+
+func (r *HTMLRenderer) renderInline(w util.BufWriter, source []byte, node gast.Node, entering bool) (gast.WalkStatus, error) {
+	if !entering {
+		return gast.WalkContinue, nil
+	}
+
+	if err := r.render(w, &node.(*Inline).Invocation, true); err != nil {
+		return gast.WalkStop, err
+	}
+
+	return gast.WalkSkipChildren, nil
+}
+
+func (r *HTMLRenderer) render(w util.BufWriter, s *Invocation, inline bool) error {
+	t := s.Templates.Lookup(s.Name + ".html")
+	if r.XHTML {
+		if tx := s.Templates.Lookup(s.Name + ".xhtml"); tx != nil {
+			t = tx
+		}
+	}
+
+	data := Data{
+		Name:    s.Name,
+		Params:  s.Params,
+		Line:    s.Line,
+		IsXHTML: r.XHTML,
+	}
+
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, data); err != nil {
+		return &Error{Line: s.Line, Name: s.Name, Err: err}
+	}
+
+	// Template files usually end with a newline, which should not
+	// end up in the middle of a paragraph.
+	output := bytes.TrimRight(buffer.Bytes(), "\n")
+	_, _ = w.Write(output)
+	if !inline {
+		_ = w.WriteByte('\n')
+	}
+
+	return nil
+}
+
+type shortcodes struct {
+}
+
+// Shortcodes is a goldmark.Extender implementation.
+var Shortcodes = &shortcodes{}
+
+// Extend implements goldmark.Extender.
+func (e *shortcodes) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(NewBlockParser(), 150),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(NewInlineParser(), 150),
+		),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(NewHTMLRenderer(), 500),
+	))
+}
@@ -0,0 +1,350 @@
+package bookgen
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headBuilder writes <meta> and JSON-LD elements for the <head> of a
+// page.
+type headBuilder struct {
+	sb strings.Builder
+}
+
+// Write a <meta> element, or nothing if value is empty. attr is
+// either `property` (Open Graph) or `name` (Twitter).
+func (h *headBuilder) meta(attr, key, value string) {
+	if value == "" {
+		return
+	}
+
+	h.sb.WriteString(`<meta ` + attr + `="` + html.EscapeString(key) + `" content="` + html.EscapeString(value) + `">` + "\n")
+}
+
+func (h *headBuilder) time(key string, t time.Time) {
+	if !t.IsZero() {
+		h.meta("property", key, t.Format(time.RFC3339))
+	}
+}
+
+// Write the Twitter card for a page, which uses the same values as
+// its Open Graph metadata.
+func (h *headBuilder) twitter(title, description, image string) {
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+
+	h.meta("name", "twitter:card", card)
+	h.meta("name", "twitter:title", title)
+	h.meta("name", "twitter:description", description)
+	h.meta("name", "twitter:image", image)
+}
+
+// Write a schema.org JSON-LD <script> element. Empty values in data
+// are left out.
+func (h *headBuilder) jsonLD(data map[string]any) {
+	data["@context"] = "https://schema.org"
+	removeEmptyValues(data)
+
+	// json.Marshal escapes <, > and &, so the output cannot close the
+	// <script> element early.
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	h.sb.WriteString(`<script type="application/ld+json">`)
+	h.sb.Write(b)
+	h.sb.WriteString("</script>\n")
+}
+
+func (h *headBuilder) html() template.HTML {
+	return template.HTML(h.sb.String())
+}
+
+func removeEmptyValues(data map[string]any) {
+	for key, value := range data {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				delete(data, key)
+			}
+		case []string:
+			if len(v) == 0 {
+				delete(data, key)
+			}
+		case []map[string]any:
+			if len(v) == 0 {
+				delete(data, key)
+			}
+		case map[string]any:
+			removeEmptyValues(v)
+			if len(v) == 0 || (len(v) == 1 && v["@type"] != nil) {
+				delete(data, key)
+			}
+		case nil:
+			delete(data, key)
+		}
+	}
+}
+
+// Returns the absolute URL of name relative to base, or an empty string
+// if base is not an absolute URL.
+func absoluteURL(base, name string) string {
+	u, err := url.Parse(base)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+
+	joined, err := url.JoinPath(base, name)
+	if err != nil {
+		return ""
+	}
+
+	return joined
+}
+
+// Open Graph locales are written like `en_US`.
+func openGraphLocale(languageCode string) string {
+	return strings.ReplaceAll(languageCode, "-", "_")
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// Returns the ISBN of an ID written either as a bare ISBN-10/ISBN-13
+// or with an `isbn:` prefix, ignoring hyphens and spaces.
+func isbnFromID(id string) (string, bool) {
+	id = strings.TrimSpace(id)
+	if len(id) > 5 && strings.EqualFold(id[:5], "isbn:") {
+		id = id[5:]
+	}
+
+	isbn := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, id)
+
+	if len(isbn) != 10 && len(isbn) != 13 {
+		return "", false
+	}
+
+	for i, r := range isbn {
+		if (r < '0' || r > '9') && !(r == 'X' && i == 9 && len(isbn) == 10) {
+			return "", false
+		}
+	}
+
+	return isbn, true
+}
+
+func authorsJSONLD(authors []Author) []map[string]any {
+	people := make([]map[string]any, 0, len(authors))
+	for _, author := range authors {
+		people = append(people, map[string]any{
+			"@type": "Person",
+			"name":  author.Name,
+		})
+	}
+
+	return people
+}
+
+// SocialMetadata returns Open Graph, Twitter card and schema.org
+// JSON-LD metadata for the <head> of the collection index page.
+func (c *Collection) SocialMetadata() template.HTML {
+	var h headBuilder
+	pageURL := absoluteURL(c.BaseURL, "index.html")
+
+	h.meta("property", "og:type", "website")
+	h.meta("property", "og:title", c.Title)
+	h.meta("property", "og:description", c.Description)
+	h.meta("property", "og:url", pageURL)
+	h.meta("property", "og:locale", openGraphLocale(c.LanguageCode))
+	h.twitter(c.Title, c.Description, "")
+
+	h.jsonLD(map[string]any{
+		"@type":       "WebSite",
+		"name":        c.Title,
+		"description": c.Description,
+		"url":         pageURL,
+		"inLanguage":  c.LanguageCode,
+	})
+
+	return h.html()
+}
+
+// Returns the absolute URL of the cover image, or an empty string if
+// there is none. Generated SVG covers are left out because social
+// media sites do not show SVG previews.
+func (b *Book) coverImageURL() string {
+	if b.CoverImageName == "" || (b.CoverImage != nil && b.CoverImage.Generated() != nil) {
+		return ""
+	}
+
+	return absoluteURL(b.BaseURL, path.Clean(filepath.ToSlash(b.CoverImageName)))
+}
+
+// SocialMetadata returns Open Graph, Twitter card and schema.org
+// JSON-LD metadata for the <head> of the book's index page.
+func (b *Book) SocialMetadata() template.HTML {
+	var h headBuilder
+	pageURL := absoluteURL(b.BaseURL, "index.html")
+	image := b.coverImageURL()
+
+	title := b.Title
+	if b.Subtitle != "" {
+		title += ": " + b.Subtitle
+	}
+
+	description := b.Description
+	if description == "" {
+		description = b.Summary.Text
+	}
+
+	h.meta("property", "og:type", "book")
+	h.meta("property", "og:title", title)
+	h.meta("property", "og:description", description)
+	h.meta("property", "og:url", pageURL)
+	h.meta("property", "og:locale", openGraphLocale(b.LanguageCode))
+	if b.Parent != nil {
+		h.meta("property", "og:site_name", b.Parent.Title)
+	}
+
+	h.meta("property", "og:image", image)
+	if image != "" && b.CoverImage != nil {
+		h.meta("property", "og:image:width", strconv.Itoa(b.CoverImage.Width))
+		h.meta("property", "og:image:height", strconv.Itoa(b.CoverImage.Height))
+	}
+
+	var isbns []string
+	for _, id := range b.IDs {
+		if isbn, ok := isbnFromID(id); ok {
+			isbns = append(isbns, isbn)
+			h.meta("property", "book:isbn", isbn)
+		}
+	}
+	h.time("book:release_date", b.DatePublished)
+	for _, tag := range b.Tags {
+		h.meta("property", "book:tag", tag)
+	}
+
+	h.twitter(title, description, image)
+
+	data := map[string]any{
+		"@type":               "Book",
+		"name":                b.Title,
+		"alternativeHeadline": b.Subtitle,
+		"description":         description,
+		"url":                 pageURL,
+		"image":               image,
+		"author":              authorsJSONLD(b.Authors),
+		"inLanguage":          b.LanguageCode,
+		"datePublished":       formatDate(b.DatePublished),
+		"dateModified":        formatDate(b.DateModified),
+		"copyrightNotice":     b.Copyright,
+		"keywords":            strings.Join(b.Tags, ", "),
+	}
+
+	if len(isbns) > 0 {
+		data["isbn"] = isbns[0]
+	}
+
+	if b.Series.Name != "" {
+		data["isPartOf"] = map[string]any{
+			"@type": "BookSeries",
+			"name":  b.Series.Name,
+		}
+
+		if b.Series.Number != 0 {
+			data["position"] = strconv.FormatFloat(float64(b.Series.Number), 'f', -1, 32)
+		}
+	}
+
+	h.jsonLD(data)
+
+	return h.html()
+}
+
+// SocialMetadata returns Open Graph, Twitter card and schema.org
+// JSON-LD metadata for the <head> of the chapter's page. The book's
+// cover image is used as the image of the chapter.
+func (c *Chapter) SocialMetadata() template.HTML {
+	var h headBuilder
+
+	book := c.Parent
+	if book == nil {
+		book = &Book{}
+	}
+
+	pageURL := absoluteURL(book.BaseURL, c.PageName+".html")
+	image := book.coverImageURL()
+
+	description := c.Description
+	if description == "" {
+		description = c.Summary.Text
+	}
+	if description == "" {
+		description = book.Description
+	}
+
+	authors := c.Authors
+	if len(authors) == 0 {
+		authors = book.Authors
+	}
+
+	h.meta("property", "og:type", "article")
+	h.meta("property", "og:title", c.Title)
+	h.meta("property", "og:description", description)
+	h.meta("property", "og:url", pageURL)
+	h.meta("property", "og:locale", openGraphLocale(c.LanguageCode))
+	h.meta("property", "og:site_name", book.Title)
+	h.meta("property", "og:image", image)
+	h.time("article:published_time", c.DatePublished)
+	h.time("article:modified_time", c.DateModified)
+	h.twitter(c.Title, description, image)
+
+	data := map[string]any{
+		"@type":               "Chapter",
+		"name":                c.Title,
+		"alternativeHeadline": c.Subtitle,
+		"description":         description,
+		"url":                 pageURL,
+		"image":               image,
+		"author":              authorsJSONLD(authors),
+		"inLanguage":          c.LanguageCode,
+		"datePublished":       formatDate(c.DatePublished),
+		"dateModified":        formatDate(c.DateModified),
+		"isPartOf": map[string]any{
+			"@type": "Book",
+			"name":  book.Title,
+			"url":   absoluteURL(book.BaseURL, "index.html"),
+		},
+	}
+
+	for i, chapter := range book.Chapters {
+		if chapter.PageName == c.PageName {
+			data["position"] = i + 1
+			break
+		}
+	}
+
+	h.jsonLD(data)
+
+	return h.html()
+}
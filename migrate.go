@@ -0,0 +1,424 @@
+package bookgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JessebotX/bookgen/internal/meta"
+)
+
+// Version of the configuration format (Collection.ConfigFormatVersion)
+// that this version of bookgen decodes. Files of older versions are
+// migrated while decoding, and can be rewritten with MigrateCollection.
+const CurrentConfigFormatVersion = 0
+
+// Kind of file that a configMigration changes.
+type configKind int
+
+const (
+	configKindCollection configKind = iota
+	configKindBook
+	configKindChapter
+)
+
+// configMigration changes the keys of configuration files and front
+// matter from the version before it into its version.
+type configMigration struct {
+	version int
+
+	// Top-level keys renamed in each kind of file, by their lower case
+	// name.
+	renames map[configKind]map[string]string
+}
+
+// Migrations of every version after 0, in order. There are none yet,
+// as the format has not changed since version 0.
+var configMigrations = []configMigration{}
+
+// Version that files are migrated into, which is the version of the
+// last of configMigrations. Only changed by tests, along with
+// configMigrations.
+var latestConfigVersion = CurrentConfigFormatVersion
+
+// Returns the configuration format version set in params, which is 0
+// if it is not set.
+func configVersion(params map[string]any) (int, error) {
+	value, ok := params[paramKey(params, "configFormatVersion")]
+	if !ok {
+		return 0, nil
+	}
+
+	version, ok := schemaNumber(value)
+	if !ok || version != float64(int(version)) || version < 0 {
+		return 0, fmt.Errorf("invalid value `%v` for key `configFormatVersion`. Must be a whole number.", value)
+	}
+
+	if int(version) > latestConfigVersion {
+		return 0, fmt.Errorf("configuration format version %v is newer than version %v, which is the latest that this version of bookgen supports. Update bookgen to build this collection.", version, latestConfigVersion)
+	}
+
+	return int(version), nil
+}
+
+// Returns the configuration format version of the files of the book,
+// which is the version of its collection.
+func (b *Book) configVersion() int {
+	if b == nil || b.Parent == nil {
+		return latestConfigVersion
+	}
+
+	return b.Parent.ConfigFormatVersion
+}
+
+// keyRename is a top-level key renamed by migrateParams.
+type keyRename struct {
+	key    string
+	newKey string
+}
+
+// Migrate params of a kind of file from version into the current
+// version. Keys are not renamed if the new key is already set.
+func migrateParams(kind configKind, version int, params map[string]any) []keyRename {
+	var renames []keyRename
+	for _, migration := range configMigrations {
+		if migration.version <= version {
+			continue
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(params)) {
+			newKey, ok := migration.renames[kind][strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+
+			if _, exists := params[paramKey(params, newKey)]; exists {
+				continue
+			}
+
+			params[newKey] = params[key]
+			delete(params, key)
+
+			// A key renamed by an earlier migration keeps its
+			// original name in the file
+			i := slices.IndexFunc(renames, func(r keyRename) bool { return r.newKey == key })
+			if i >= 0 {
+				renames[i].newKey = newKey
+			} else {
+				renames = append(renames, keyRename{key: key, newKey: newKey})
+			}
+		}
+	}
+
+	return renames
+}
+
+// FileMigration describes the changes made to a file by
+// MigrateCollection.
+type FileMigration struct {
+	Path    string
+	Changes []string
+}
+
+// MigrateOptions represents the settings of MigrateCollection.
+type MigrateOptions struct {
+	// DryRun returns the changes without writing any files.
+	DryRun bool
+}
+
+// Rewrite the configuration files and front matter of the structured
+// directory into the current configuration format version, and set
+// the collection's `configFormatVersion`. Only the changed keys are
+// rewritten, so that comments and formatting are kept.
+//
+// Files of environments (e.g. bookgen.production.yml) are migrated
+// along with the files they are merged over.
+func MigrateCollection(workingDir string, opts MigrateOptions) ([]FileMigration, error) {
+	src := osSource(workingDir)
+
+	nameConfig, format, err := findConfigFile(src, ".", "bookgen")
+	if err != nil {
+		return nil, fmt.Errorf("collection: %w", err)
+	}
+
+	data, err := fs.ReadFile(src.fsys, nameConfig)
+	if err != nil {
+		return nil, fmt.Errorf("collection: failed to read file `%v`. %w", src.path(nameConfig), err)
+	}
+
+	var params map[string]any
+	if err := meta.Unmarshal(format, data, &params); err != nil {
+		return nil, fmt.Errorf("collection: failed to decode %v in `%v`. %w", strings.ToUpper(string(format)), src.path(nameConfig), err)
+	}
+
+	version, err := configVersion(params)
+	if err != nil {
+		return nil, fmt.Errorf("collection: %w", err)
+	}
+
+	m := migrator{src: src, version: version, dryRun: opts.DryRun}
+
+	// ---
+	// Migrate collection
+	// ---
+	if err := m.migrateConfigFiles(".", "bookgen", configKindCollection); err != nil {
+		return m.migrated, fmt.Errorf("collection: %w", err)
+	}
+
+	// ---
+	// Migrate books and chapters
+	// ---
+	items, err := fs.ReadDir(src.fsys, "books")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return m.migrated, fmt.Errorf("collection: failed to read books directory %v. %w", src.path("books"), err)
+	}
+
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+
+		dir := path.Join("books", item.Name())
+		if err := m.migrateConfigFiles(dir, "bookgen-book", configKindBook); err != nil {
+			return m.migrated, fmt.Errorf("book `%v`: %w", item.Name(), err)
+		}
+
+		chaptersDir := path.Join(dir, "chapters")
+		chapters, err := fs.ReadDir(src.fsys, chaptersDir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return m.migrated, fmt.Errorf("book `%v`: failed to read chapters directory at `%v`. %w", item.Name(), src.path(chaptersDir), err)
+		}
+
+		for _, chapter := range chapters {
+			name := path.Join(chaptersDir, chapter.Name())
+			if chapter.IsDir() {
+				// Chapter bundle
+				name = path.Join(name, "index.md")
+				if _, err := fs.Stat(src.fsys, name); err != nil {
+					continue
+				}
+			} else if !strings.HasSuffix(chapter.Name(), ".md") {
+				continue
+			}
+
+			if err := m.migrateFile(name, configKindChapter); err != nil {
+				return m.migrated, fmt.Errorf("book `%v`: %w", item.Name(), err)
+			}
+		}
+	}
+
+	// ---
+	// Set version
+	// ---
+	if version < latestConfigVersion {
+		if err := m.setVersion(nameConfig, format); err != nil {
+			return m.migrated, fmt.Errorf("collection: %w", err)
+		}
+	}
+
+	return m.migrated, nil
+}
+
+// migrator holds the state of a single MigrateCollection.
+type migrator struct {
+	src      source
+	version  int
+	dryRun   bool
+	migrated []FileMigration
+}
+
+// Migrate the configuration files named base in dir, including the
+// files of every environment.
+func (m *migrator) migrateConfigFiles(dir, base string, kind configKind) error {
+	items, err := fs.ReadDir(m.src.fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory `%v`. %w", m.src.path(dir), err)
+	}
+
+	for _, item := range items {
+		name := item.Name()
+		ext := path.Ext(name)
+		if item.IsDir() || meta.FormatFromExtension(ext) == "" {
+			continue
+		}
+
+		// bookgen.yml or bookgen.ENVIRONMENT.yml
+		stem := strings.TrimSuffix(name, ext)
+		if stem != base && !strings.HasPrefix(stem, base+".") {
+			continue
+		}
+
+		if err := m.migrateFile(path.Join(dir, name), kind); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Migrate a configuration file, or the front matter of a chapter.
+func (m *migrator) migrateFile(name string, kind configKind) error {
+	data, err := fs.ReadFile(m.src.fsys, name)
+	if err != nil {
+		return fmt.Errorf("failed to read file `%v`. %w", m.src.path(name), err)
+	}
+
+	// Only the front matter of chapters is changed
+	params, format, lineOffset := data, meta.FormatFromExtension(path.Ext(name)), 0
+	if kind == configKindChapter {
+		params, format, lineOffset = frontMatter(data)
+		if format == "" {
+			return nil
+		}
+	}
+
+	var values map[string]any
+	if err := meta.Unmarshal(format, params, &values); err != nil {
+		return fmt.Errorf("failed to decode %v in `%v`. %w", strings.ToUpper(string(format)), m.src.path(name), err)
+	}
+
+	renames := migrateParams(kind, m.version, values)
+	if len(renames) == 0 {
+		return nil
+	}
+
+	// Rename each key where it is written
+	positions := keyPositions(format, params)
+	lines := strings.SplitAfter(string(data), "\n")
+	changes := make([]string, len(renames))
+	for i, rename := range renames {
+		position, ok := positions[strings.ToLower(rename.key)]
+		if !ok {
+			return fmt.Errorf("failed to find key `%v` in `%v`", rename.key, m.src.path(name))
+		}
+
+		line := position[0] + lineOffset - 1
+		lines[line], ok = renameKey(lines[line], position[1]-1, rename.key, rename.newKey)
+		if !ok {
+			return fmt.Errorf("failed to rename key `%v` in `%v:%v`", rename.key, m.src.path(name), line+1)
+		}
+
+		changes[i] = fmt.Sprintf("renamed key `%v` to `%v`", rename.key, rename.newKey)
+	}
+
+	m.migrated = append(m.migrated, FileMigration{Path: m.src.path(name), Changes: changes})
+	if m.dryRun {
+		return nil
+	}
+
+	return m.writeFile(name, []byte(strings.Join(lines, "")))
+}
+
+// Replace key, which may be quoted, at the byte offset column of line
+// with newKey.
+func renameKey(line string, column int, key, newKey string) (string, bool) {
+	if column < 0 || column >= len(line) {
+		return line, false
+	}
+
+	rest := line[column:]
+	if quote := rest[0]; quote == '"' || quote == '\'' {
+		quoted := string(quote) + key + string(quote)
+		if !strings.HasPrefix(rest, quoted) {
+			return line, false
+		}
+		return line[:column] + string(quote) + newKey + string(quote) + rest[len(quoted):], true
+	}
+
+	if !strings.HasPrefix(strings.ToLower(rest), strings.ToLower(key)) {
+		return line, false
+	}
+
+	return line[:column] + newKey + rest[len(key):], true
+}
+
+// Set `configFormatVersion` in the collection's configuration file to
+// the current version.
+func (m *migrator) setVersion(name string, format meta.Format) error {
+	data, err := fs.ReadFile(m.src.fsys, name)
+	if err != nil {
+		return fmt.Errorf("failed to read file `%v`. %w", m.src.path(name), err)
+	}
+
+	version := strconv.Itoa(latestConfigVersion)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	if position, ok := keyPositions(format, data)["configformatversion"]; ok {
+		// Replace the value after the key
+		i := position[0] - 1
+		line := lines[i]
+		separator := strings.IndexAny(line[position[1]-1:], ":=") + position[1]
+		value := strings.TrimLeft(line[separator:], " \t")
+		end := strings.IndexFunc(value, func(r rune) bool {
+			return !('0' <= r && r <= '9')
+		})
+		if end < 0 {
+			end = len(value)
+		}
+		lines[i] = line[:len(line)-len(value)] + version + value[end:]
+	} else {
+		// Add the key after the comments at the start of the file.
+		// It cannot go at the end, as a key after a table in TOML
+		// would belong to the table.
+		start := 0
+		for start < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[start]), "#") {
+			start++
+		}
+
+		switch format {
+		case meta.FormatYAML:
+			lines = slices.Insert(lines, start, "configFormatVersion: "+version+"\n")
+		case meta.FormatTOML:
+			lines = slices.Insert(lines, start, "configFormatVersion = "+version+"\n")
+		case meta.FormatJSON:
+			start := slices.IndexFunc(lines, func(line string) bool {
+				return strings.Contains(line, "{")
+			})
+			if start < 0 {
+				return fmt.Errorf("failed to find the start of the JSON object in `%v`", m.src.path(name))
+			}
+
+			brace := strings.Index(lines[start], "{") + 1
+			empty := bytes.Equal(bytes.TrimSpace(data), []byte("{}"))
+			entry := "\n  \"configFormatVersion\": " + version
+			if !empty {
+				entry += ","
+			}
+			lines[start] = lines[start][:brace] + entry + lines[start][brace:]
+		}
+	}
+
+	m.migrated = append(m.migrated, FileMigration{
+		Path:    m.src.path(name),
+		Changes: []string{fmt.Sprintf("set `configFormatVersion` to %v", version)},
+	})
+	if m.dryRun {
+		return nil
+	}
+
+	return m.writeFile(name, []byte(strings.Join(lines, "")))
+}
+
+// Write data into the file at name, keeping its permissions.
+func (m *migrator) writeFile(name string, data []byte) error {
+	file := m.src.path(name)
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write file `%v`. %w", filepath.Clean(file), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package bookgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Register a migration into version 1 that renames `name` to `title`
+// in configuration files, and `heading` to `title` in front matter.
+func withTestMigration(t *testing.T) {
+	t.Helper()
+
+	migrations, latest := configMigrations, latestConfigVersion
+	t.Cleanup(func() {
+		configMigrations, latestConfigVersion = migrations, latest
+	})
+
+	configMigrations = []configMigration{
+		{
+			version: 1,
+			renames: map[configKind]map[string]string{
+				configKindCollection: {"name": "title"},
+				configKindBook:       {"name": "title"},
+				configKindChapter:    {"heading": "title"},
+			},
+		},
+	}
+	latestConfigVersion = 1
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+
+	for name, data := range want {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("%v:\ngot:\n%s\nwant:\n%s", name, got, data)
+		}
+	}
+}
+
+func TestMigrateCollection(t *testing.T) {
+	withTestMigration(t)
+
+	files := map[string]string{
+		"bookgen.yml": "# Settings of the collection\n" +
+			"name: My Books # shown on the index\n" +
+			"baseURL: https://example.com/\n",
+		"bookgen.production.yml": "# Production\n" +
+			"'name': Published Books\n",
+		"books/a/bookgen-book.yml": "# A book\n" +
+			"Name: \"A Book\"\n" +
+			"status: Ongoing # still writing\n",
+		"books/a/chapters/one.md": "---\n" +
+			"heading: One # first\n" +
+			"published: 2024-01-01\n" +
+			"---\n" +
+			"heading: not front matter\n",
+		"books/a/chapters/two/index.md": "+++\n" +
+			"# TOML front matter\n" +
+			"heading = \"Two\"\n" +
+			"+++\n" +
+			"Text\n",
+		"books/b/bookgen-book.json": "{\n" +
+			"  \"name\": \"B\",\n" +
+			"  \"status\": \"completed\"\n" +
+			"}\n",
+	}
+	dir := writeFiles(t, files)
+
+	migrated, err := MigrateCollection(dir, MigrateOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 7 {
+		t.Errorf("got %d migrated files in dry run, want 7: %v", len(migrated), migrated)
+	}
+	checkFiles(t, dir, files)
+
+	if _, err := MigrateCollection(dir, MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFiles(t, dir, map[string]string{
+		"bookgen.yml": "# Settings of the collection\n" +
+			"configFormatVersion: 1\n" +
+			"title: My Books # shown on the index\n" +
+			"baseURL: https://example.com/\n",
+		"bookgen.production.yml": "# Production\n" +
+			"'title': Published Books\n",
+		"books/a/bookgen-book.yml": "# A book\n" +
+			"title: \"A Book\"\n" +
+			"status: Ongoing # still writing\n",
+		"books/a/chapters/one.md": "---\n" +
+			"title: One # first\n" +
+			"published: 2024-01-01\n" +
+			"---\n" +
+			"heading: not front matter\n",
+		"books/a/chapters/two/index.md": "+++\n" +
+			"# TOML front matter\n" +
+			"title = \"Two\"\n" +
+			"+++\n" +
+			"Text\n",
+		"books/b/bookgen-book.json": "{\n" +
+			"  \"title\": \"B\",\n" +
+			"  \"status\": \"completed\"\n" +
+			"}\n",
+	})
+
+	// Files already at the latest version are left alone
+	migrated, err = MigrateCollection(dir, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 0 {
+		t.Errorf("got %d migrated files after migrating, want 0: %v", len(migrated), migrated)
+	}
+}
+
+func TestMigrateSetVersion(t *testing.T) {
+	withTestMigration(t)
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			"bookgen.yml",
+			"configFormatVersion: 0 # old\nname: X\n",
+			"configFormatVersion: 1 # old\ntitle: X\n",
+		},
+		{
+			"bookgen.toml",
+			"# Collection\nname = \"X\"\n\n[highlighting]\nstyle = \"monokai\"\n",
+			"# Collection\nconfigFormatVersion = 1\ntitle = \"X\"\n\n[highlighting]\nstyle = \"monokai\"\n",
+		},
+		{
+			"bookgen.json",
+			"{\n  \"name\": \"X\"\n}\n",
+			"{\n  \"configFormatVersion\": 1,\n  \"title\": \"X\"\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{test.name: test.data})
+			if _, err := MigrateCollection(dir, MigrateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			checkFiles(t, dir, map[string]string{test.name: test.want})
+		})
+	}
+}
+
+func TestMigrateWhileDecoding(t *testing.T) {
+	withTestMigration(t)
+
+	fsys := fstest.MapFS{
+		"bookgen.yml":                     {Data: []byte("name: Old Collection\nbaseURL: https://example.com/\n")},
+		"books/a/bookgen-book.yml":        {Data: []byte("name: Old Book\n")},
+		"books/a/chapters/one.md":         {Data: []byte("---\nheading: Old Chapter\npublished: 2024-01-01\n---\nText\n")},
+		"books/a/chapters/two.md":         {Data: []byte("---\ntitle: New Chapter\npublished: 2024-01-02\n---\nText\n")},
+		"books/a/chapters/three/index.md": {Data: []byte("---\nheading: Bundle\npublished: 2024-01-03\n---\nText\n")},
+	}
+
+	c, err := DecodeCollectionFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Title != "Old Collection" {
+		t.Errorf("got collection title %q", c.Title)
+	}
+	if len(c.Warnings) == 0 {
+		t.Error("no warning about the old configuration format version")
+	}
+	if len(c.Books) != 1 || c.Books[0].Title != "Old Book" {
+		t.Fatalf("got books %+v", c.Books)
+	}
+
+	titles := make(map[string]string)
+	for _, chapter := range c.Books[0].Chapters {
+		titles[chapter.PageName] = chapter.Title
+	}
+	want := map[string]string{"one": "Old Chapter", "two": "New Chapter", "three": "Bundle"}
+	for name, title := range want {
+		if titles[name] != title {
+			t.Errorf("chapter `%v`: got title %q, want %q", name, titles[name], title)
+		}
+	}
+
+	errs, err := ValidateCollectionFS(fsys, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range errs {
+		t.Errorf("validate: %v", e)
+	}
+}
@@ -0,0 +1,154 @@
+package bookgen
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Prefix of environment variables that override keys of the
+// collection's configuration (see EnvOverrides).
+const EnvPrefix = "BOOKGEN_"
+
+// Override sets a key of the collection's configuration, replacing the
+// value in the configuration files.
+type Override struct {
+	// Path of the key, e.g. `baseURL`, `highlighting.style` or
+	// `extra.x` for custom values. Keys are matched without case.
+	Key string
+
+	// Used as is for keys that are strings (e.g. `title=1984`).
+	// Otherwise parsed as a YAML value, so that `true` is a bool and
+	// `[a, b]` is a list. Values that are not valid YAML are strings.
+	Value string
+
+	// Where the override comes from, shown in messages (e.g. `--set`
+	// or the name of an environment variable).
+	Origin string
+}
+
+// Parse an override written as `key=value`.
+func ParseOverride(s, origin string) (Override, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return Override{}, fmt.Errorf("invalid override `%v`. Must be written as `key=value`.", s)
+	}
+
+	return Override{Key: key, Value: value, Origin: origin}, nil
+}
+
+// Returns the overrides of the environment variables in environ (as
+// returned by os.Environ) starting with EnvPrefix. Underscores
+// separate the parts of a key, e.g. `BOOKGEN_HIGHLIGHTING_STYLE` sets
+// `highlighting.style`, so keys containing underscores (such as custom
+// keys in `extra`) can only be set with a configuration file or
+// `--set`.
+//
+// Variables whose key is not in CollectionSchema are ignored, as they
+// may be used by something else (e.g. `BOOKGEN_HOME`).
+func EnvOverrides(environ []string) []Override {
+	schema := CollectionSchema()
+
+	var overrides []Override
+	for _, variable := range environ {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok {
+			continue
+		}
+
+		key, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok || key == "" {
+			continue
+		}
+
+		key = strings.ReplaceAll(strings.ToLower(key), "_", ".")
+		if !schema.hasKey(key) {
+			continue
+		}
+
+		overrides = append(overrides, Override{
+			Key:    key,
+			Value:  value,
+			Origin: name,
+		})
+	}
+
+	// os.Environ is not sorted
+	slices.SortFunc(overrides, func(a, b Override) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return overrides
+}
+
+// Returns the value of the override for a key with a schema (see
+// Schema.keySchema), which is nil if the key may have any value.
+func (o Override) value(schema *Schema) any {
+	if o.Value == "" || (schema != nil && schema.Type == "string") {
+		return o.Value
+	}
+
+	var v any
+	if err := yaml.Unmarshal([]byte(o.Value), &v); err != nil || v == nil {
+		return o.Value
+	}
+
+	return v
+}
+
+// Set the value of the override in params, creating tables as needed.
+// The type of the value is that of the key in schema.
+func (o Override) apply(params map[string]any, schema *Schema) {
+	parts := strings.Split(o.Key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		key := paramKey(params, part)
+		table, ok := params[key].(map[string]any)
+		if !ok {
+			table = make(map[string]any)
+			params[key] = table
+		}
+		params = table
+	}
+
+	last := parts[len(parts)-1]
+	keySchema, _ := schema.keySchema(o.Key)
+	params[paramKey(params, last)] = o.value(keySchema)
+}
+
+// Deep-merge src into dst. Tables are merged key by key, and other
+// values in src replace those in dst.
+func mergeParams(dst, src map[string]any) {
+	for _, key := range slices.Sorted(maps.Keys(src)) {
+		value := src[key]
+		dstKey := paramKey(dst, key)
+
+		srcTable, srcOK := value.(map[string]any)
+		dstTable, dstOK := dst[dstKey].(map[string]any)
+		if srcOK && dstOK {
+			mergeParams(dstTable, srcTable)
+			continue
+		}
+
+		dst[dstKey] = value
+	}
+}
+
+// Returns the key in params matching key without case, as keys are
+// decoded, or key if there is none.
+func paramKey(params map[string]any, key string) string {
+	if _, ok := params[key]; ok {
+		return key
+	}
+
+	for k := range params {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+
+	return key
+}
@@ -0,0 +1,123 @@
+package bookgen
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func decodeWithOverrides(t *testing.T, overrides []Override) (Collection, error) {
+	t.Helper()
+
+	fsys := fstest.MapFS{
+		"bookgen.yml": {Data: []byte("title: Collection\nbaseURL: https://example.com/\n")},
+	}
+
+	return DecodeCollectionFSContext(context.Background(), fsys, DecodeOptions{Overrides: overrides})
+}
+
+func TestOverrideStringKeys(t *testing.T) {
+	tests := []struct {
+		override string
+		want     func(c Collection) string
+		value    string
+	}{
+		{"title=1984", func(c Collection) string { return c.Title }, "1984"},
+		{"description=Part 1: the start", func(c Collection) string { return c.Description }, "Part 1: the start"},
+		{"baseURL=https://example.org/books/", func(c Collection) string { return c.BaseURL }, "https://example.org/books/"},
+		{"title=true", func(c Collection) string { return c.Title }, "true"},
+		{"title=[a, b]", func(c Collection) string { return c.Title }, "[a, b]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.override, func(t *testing.T) {
+			override, err := ParseOverride(test.override, "--set")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := decodeWithOverrides(t, []Override{override})
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if got := test.want(c); got != test.value {
+				t.Errorf("got %q, want %q", got, test.value)
+			}
+		})
+	}
+}
+
+func TestEnvOverrideStringKey(t *testing.T) {
+	overrides := EnvOverrides([]string{"BOOKGEN_TITLE=2001", "BOOKGEN_HOME=/tmp"})
+	if len(overrides) != 1 {
+		t.Fatalf("got %d overrides, want 1: %v", len(overrides), overrides)
+	}
+
+	c, err := decodeWithOverrides(t, overrides)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if c.Title != "2001" {
+		t.Errorf("got title %q, want %q", c.Title, "2001")
+	}
+}
+
+func TestOverrideValueTypes(t *testing.T) {
+	schema := CollectionSchema()
+	tests := []struct {
+		override string
+		key      []string
+		want     any
+	}{
+		{"summaryLength=5", []string{"summaryLength"}, uint64(5)},
+		{"highlighting.lineNumbers=true", []string{"highlighting", "lineNumbers"}, true},
+		{"extra.count=3", []string{"extra", "count"}, uint64(3)},
+		{"extra.names=[a, b]", []string{"extra", "names"}, []any{"a", "b"}},
+		{"extra.note=a: b", []string{"extra", "note"}, map[string]any{"a": "b"}},
+		{"extra.empty=", []string{"extra", "empty"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.override, func(t *testing.T) {
+			override, err := ParseOverride(test.override, "--set")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params := make(map[string]any)
+			override.apply(params, schema)
+
+			var got any = params
+			for _, key := range test.key {
+				table, ok := got.(map[string]any)
+				if !ok {
+					t.Fatalf("`%v` is not a table in %v", key, params)
+				}
+				got = table[key]
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	for _, s := range []string{"", "title", "=value"} {
+		if _, err := ParseOverride(s, "--set"); err == nil {
+			t.Errorf("ParseOverride(%q) did not fail", s)
+		}
+	}
+
+	override, err := ParseOverride("title=a=b", "--set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if override.Key != "title" || override.Value != "a=b" {
+		t.Errorf("got %+v", override)
+	}
+}
@@ -0,0 +1,423 @@
+// Package render builds the output of a bookgen collection, such as a
+// website, from its source directory.
+package render
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JessebotX/bookgen"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	minhtml "github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/svg"
+)
+
+const (
+	DirPerms  = 0755
+	FilePerms = 0644
+)
+
+// Format of the output of a build.
+type Format string
+
+const (
+	// FormatWebsite renders the collection with its layouts.
+	FormatWebsite Format = "website"
+
+	// FormatJSON writes the collection as JSON (see
+	// bookgen.CollectionExport and bookgen.BookExport).
+	FormatJSON Format = "json"
+)
+
+// Valid values for BuildOptions.Format.
+var FormatValidValues = []Format{FormatWebsite, FormatJSON}
+
+// BuildOptions represents the settings of a build.
+type BuildOptions struct {
+	// Directory containing the source files with a bookgen.yml (or
+	// .yaml, .toml or .json).
+	InputDirectory string
+
+	// Directory to write the output into. It cannot be the same as
+	// the input directory.
+	OutputDirectory string
+
+	// Input is read instead of InputDirectory if it is not nil, e.g.
+	// an embedded filesystem or a zip archive.
+	Input fs.FS
+
+	// Output is written into instead of OutputDirectory if it is not
+	// nil, e.g. a MemFS.
+	Output WriteFS
+
+	// Defaults to FormatWebsite.
+	Format Format
+
+	// Minify output files.
+	Minify bool
+
+	// Report unknown keys in configuration files and front matter as
+	// warnings instead of failing the build (see
+	// bookgen.DecodeOptions).
+	Lenient bool
+
+	// Environment whose configuration files are merged over the
+	// collection's and books' (see bookgen.DecodeOptions).
+	Environment string
+
+	// Overrides of keys in the collection's configuration, e.g. from
+	// bookgen.EnvOverrides.
+	Overrides []bookgen.Override
+
+	// Include drafts, scheduled and expired books and chapters (see
+	// bookgen.DecodeOptions).
+	Drafts  bool
+	Future  bool
+	Expired bool
+
+	// Layouts containing the templates and static files of the
+	// website. Defaults to the collection's layouts directory
+	// (Internal.LayoutsDirectory) in the input. Shortcodes are always
+	// read from the input.
+	Layouts fs.FS
+
+	// Logger for messages about the build, which is also used for
+	// decoding. Warnings are logged at slog.LevelWarn, each page
+	// written at slog.LevelDebug and a summary of the build at
+	// slog.LevelInfo. Defaults to discarding all messages.
+	Logger *slog.Logger
+
+	// Progress is called whenever a step of the build is finished
+	// (see EventKind). It may be called from multiple goroutines.
+	Progress func(Event)
+}
+
+// Stage of a build.
+type Stage string
+
+const (
+	StageDecode Stage = "decode"
+	StageRender Stage = "render"
+)
+
+// EventKind is the kind of step of a build that an Event describes.
+type EventKind string
+
+const (
+	// Decode stage
+	EventBookStarted    EventKind = EventKind(bookgen.DecodeEventBookStarted)
+	EventChapterDecoded EventKind = EventKind(bookgen.DecodeEventChapterDecoded)
+	EventDecoded        EventKind = "decoded"
+
+	// Render stage
+	EventPageWritten EventKind = "page-written"
+	EventAssetCopied EventKind = "asset-copied"
+)
+
+// Event describes a finished step of a build.
+type Event struct {
+	Kind  EventKind
+	Stage Stage
+
+	// Page name of the book the step belongs to, if any.
+	Book string
+
+	// Page name of the decoded chapter for EventChapterDecoded, or the
+	// path of the written file relative to the output directory for
+	// EventPageWritten and EventAssetCopied.
+	Path string
+
+	// Number of files written so far and in total, counting pages and
+	// assets but not resized images.
+	Done  int
+	Total int
+}
+
+// Report describes a finished build.
+type Report struct {
+	// Pages written, sorted by path.
+	Pages []PageReport
+
+	// Non-fatal problems found during the build.
+	Warnings []string
+
+	// Number of books and chapters decoded.
+	Books    int
+	Chapters int
+
+	// Total size of all files written, including assets and resized
+	// images.
+	Bytes int64
+
+	DecodeDuration time.Duration
+	RenderDuration time.Duration
+	TotalDuration  time.Duration
+}
+
+// PageReport describes a page written during a build.
+type PageReport struct {
+	// Path of the page, relative to the output directory.
+	Path string
+
+	// Time it took to render and write the page.
+	Duration time.Duration
+
+	// Size of the page.
+	Bytes int
+}
+
+// builder holds the state of a single build.
+type builder struct {
+	ctx      context.Context
+	opts     BuildOptions
+	in       fs.FS
+	out      WriteFS
+	logger   *slog.Logger
+	minifier *minify.M
+
+	mu     sync.Mutex
+	report Report
+	done   int
+	total  int
+}
+
+// Build decodes the collection in BuildOptions.InputDirectory and
+// writes its output into BuildOptions.OutputDirectory. The returned
+// Report is never nil, and describes the build until it failed if err
+// is not nil.
+func Build(ctx context.Context, opts BuildOptions) (*Report, error) {
+	b := &builder{
+		ctx:    ctx,
+		opts:   opts,
+		in:     opts.Input,
+		out:    opts.Output,
+		logger: opts.Logger,
+	}
+
+	if b.logger == nil {
+		b.logger = slog.New(slog.DiscardHandler)
+	}
+
+	if b.opts.Format == "" {
+		b.opts.Format = FormatWebsite
+	}
+
+	if !slices.Contains(FormatValidValues, b.opts.Format) {
+		return &b.report, fmt.Errorf("invalid output format `%v`. Must be one of the following options: website | json.", b.opts.Format)
+	}
+
+	if b.in == nil && b.out == nil && filepath.Clean(opts.InputDirectory) == filepath.Clean(opts.OutputDirectory) {
+		return &b.report, fmt.Errorf("output directory cannot be equal to the working/input directory (`%s` and `%s` are the same).", opts.InputDirectory, opts.OutputDirectory)
+	}
+
+	if b.in == nil {
+		b.in = os.DirFS(opts.InputDirectory)
+	}
+
+	if b.out == nil {
+		if err := os.MkdirAll(opts.OutputDirectory, DirPerms); err != nil {
+			return &b.report, fmt.Errorf("failed to create output directory. %w", err)
+		}
+		b.out = DirFS(opts.OutputDirectory)
+	}
+
+	if b.opts.Minify {
+		b.minifier = newMinifier()
+	}
+
+	totalTimeStart := time.Now()
+	defer func() {
+		b.report.TotalDuration = time.Since(totalTimeStart)
+	}()
+
+	// ---
+	// Decode
+	// ---
+	if err := ctx.Err(); err != nil {
+		return &b.report, err
+	}
+
+	decodeOpts := bookgen.DecodeOptions{
+		Progress: func(e bookgen.DecodeEvent) {
+			b.progress(Event{Kind: EventKind(e.Kind), Stage: StageDecode, Book: e.Book, Path: e.Chapter})
+		},
+		Logger:      b.logger,
+		Lenient:     opts.Lenient,
+		Environment: opts.Environment,
+		Overrides:   opts.Overrides,
+		Drafts:      opts.Drafts,
+		Future:      opts.Future,
+		Expired:     opts.Expired,
+	}
+
+	var c bookgen.Collection
+	var err error
+	if opts.Input != nil {
+		c, err = bookgen.DecodeCollectionFSContext(ctx, opts.Input, decodeOpts)
+	} else {
+		c, err = bookgen.DecodeCollectionContext(ctx, opts.InputDirectory, decodeOpts)
+	}
+	if err != nil {
+		return &b.report, err
+	}
+
+	b.report.DecodeDuration = time.Since(totalTimeStart)
+	b.report.Books = len(c.Books)
+	for _, book := range c.Books {
+		b.report.Chapters += len(book.Chapters)
+	}
+	b.logger.Debug("decoded collection", "books", b.report.Books, "chapters", b.report.Chapters, "duration", b.report.DecodeDuration)
+
+	for _, warning := range c.Warnings {
+		b.warn(fmt.Sprintf("collection: %v", warning))
+	}
+
+	for _, book := range c.Books {
+		for _, warning := range book.Warnings {
+			b.warn(fmt.Sprintf("book `%v`: %v", book.PageName, warning))
+		}
+	}
+
+	b.progress(Event{Kind: EventDecoded, Stage: StageDecode})
+
+	// ---
+	// Render
+	// ---
+	renderTimeStart := time.Now()
+
+	switch b.opts.Format {
+	case FormatJSON:
+		err = b.renderJSON(&c)
+	default:
+		err = b.renderWebsite(&c)
+	}
+
+	b.report.RenderDuration = time.Since(renderTimeStart)
+
+	slices.SortFunc(b.report.Pages, func(x, y PageReport) int {
+		return strings.Compare(x.Path, y.Path)
+	})
+
+	if err == nil {
+		b.logger.Info("build finished",
+			"books", b.report.Books,
+			"chapters", b.report.Chapters,
+			"pages", len(b.report.Pages),
+			"bytes", b.report.Bytes,
+			"warnings", len(b.report.Warnings),
+			"duration", time.Since(totalTimeStart),
+		)
+	}
+
+	return &b.report, err
+}
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.Add("text/html", &minhtml.Minifier{
+		KeepDefaultAttrVals: true,
+		KeepDocumentTags:    true,
+		KeepSpecialComments: true,
+		KeepQuotes:          true,
+	})
+	m.AddFunc("text/css", css.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("^(application|text)/(x-)?(java|ecma)script$"), js.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+
+	return m
+}
+
+// Count bytes written that are not reported as an event.
+func (b *builder) addBytes(bytes int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.report.Bytes += int64(bytes)
+}
+
+func (b *builder) warn(warning string) {
+	b.logger.Warn(warning)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.report.Warnings = append(b.report.Warnings, warning)
+}
+
+func (b *builder) progress(e Event) {
+	if b.opts.Progress != nil {
+		b.opts.Progress(e)
+	}
+}
+
+// Set the total number of pages and assets that will be written, for
+// progress events.
+func (b *builder) setTotal(total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.total = total
+}
+
+// Write a page at name (relative to the output directory), minifying
+// it as mediaType if enabled. start is when rendering the page began.
+func (b *builder) writePage(name, mediaType string, data []byte, start time.Time) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	if b.minifier != nil && mediaType != "" {
+		minified, err := b.minifier.Bytes(mediaType, data)
+		if err != nil {
+			return fmt.Errorf("failed to minify `%v`. %w", name, err)
+		}
+		data = minified
+	}
+
+	if err := b.out.MkdirAll(path.Dir(name), DirPerms); err != nil {
+		return err
+	}
+
+	if err := b.out.WriteFile(name, data, FilePerms); err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	b.logger.Debug("wrote page", "path", name, "bytes", len(data), "duration", duration)
+
+	b.mu.Lock()
+	b.report.Pages = append(b.report.Pages, PageReport{Path: name, Duration: duration, Bytes: len(data)})
+	b.mu.Unlock()
+
+	b.wrote(EventPageWritten, name, len(data))
+
+	return nil
+}
+
+// Count a written page or asset of size bytes at name and report it.
+func (b *builder) wrote(kind EventKind, name string, bytes int) {
+	b.mu.Lock()
+	b.report.Bytes += int64(bytes)
+	b.done++
+	event := Event{Kind: kind, Stage: StageRender, Path: name, Done: b.done, Total: b.total}
+	b.mu.Unlock()
+
+	if book, ok := strings.CutPrefix(name, "books/"); ok {
+		event.Book, _, _ = strings.Cut(book, "/")
+	}
+
+	b.progress(event)
+}
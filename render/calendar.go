@@ -0,0 +1,101 @@
+package render
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/JessebotX/bookgen"
+)
+
+// Returns whether an iCalendar feed of upcoming releases is written
+// for the book.
+func hasCalendar(book *bookgen.Book) bool {
+	return len(book.Schedule.Days) > 0 || len(book.Releases) > 0
+}
+
+// Write an iCalendar feed (RFC 5545) of the upcoming releases of a
+// book. If no chapter is scheduled, the feed has a single event on
+// book.NextRelease.
+func (b *builder) renderBookCalendar(bookPath string, book *bookgen.Book) error {
+	start := time.Now()
+	var f bytes.Buffer
+
+	writeLine := func(name, value string) {
+		f.WriteString(foldCalendarLine(name + ":" + value))
+		f.WriteString("\r\n")
+	}
+
+	stamp := start.UTC().Format(calendarDateFormat)
+	host := "bookgen"
+	if u, err := url.Parse(book.BaseURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	writeEvent := func(uid, summary, link string, date time.Time) {
+		writeLine("BEGIN", "VEVENT")
+		writeLine("UID", uid+"@"+host)
+		writeLine("DTSTAMP", stamp)
+		writeLine("DTSTART", date.UTC().Format(calendarDateFormat))
+		writeLine("SUMMARY", escapeCalendarText(summary))
+		if link != "" {
+			writeLine("URL", link)
+		}
+		writeLine("END", "VEVENT")
+	}
+
+	writeLine("BEGIN", "VCALENDAR")
+	writeLine("VERSION", "2.0")
+	writeLine("PRODID", "-//bookgen//bookgen//EN")
+	writeLine("X-WR-CALNAME", escapeCalendarText(book.Title))
+
+	for _, r := range book.Releases {
+		link, err := url.JoinPath(book.BaseURL, r.PageName+".html")
+		if err != nil {
+			return err
+		}
+
+		writeEvent(book.PageName+"-"+r.PageName, book.Title+": "+r.Title, link, r.Date)
+	}
+
+	if len(book.Releases) == 0 && !book.NextRelease.IsZero() {
+		link, err := url.JoinPath(book.BaseURL, "index.html")
+		if err != nil {
+			return err
+		}
+
+		writeEvent(book.PageName+"-"+book.NextRelease.UTC().Format(calendarDateFormat), book.Title+": next chapter", link, book.NextRelease)
+	}
+
+	writeLine("END", "VCALENDAR")
+
+	return b.writePage(path.Join(bookPath, "calendar.ics"), "", f.Bytes(), start)
+}
+
+const calendarDateFormat = "20060102T150405Z"
+
+// Escape a TEXT value of an iCalendar property.
+func escapeCalendarText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// Fold a content line longer than 75 bytes into multiple lines, which
+// continue with a space. UTF-8 sequences are not split.
+func foldCalendarLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+
+		b.WriteRune(r)
+		width += size
+	}
+
+	return b.String()
+}
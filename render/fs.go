@@ -0,0 +1,172 @@
+package render
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+)
+
+// WriteFS is a filesystem that the output of a build is written into.
+// Names are slash-separated paths relative to its root, as in fs.FS.
+type WriteFS interface {
+	fs.FS
+
+	// MkdirAll creates a directory along with any missing parents.
+	MkdirAll(name string, perm fs.FileMode) error
+
+	// WriteFile creates or replaces the file at name. Its parent
+	// directory must exist.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+
+	// RemoveAll removes the file or directory at name and everything
+	// it contains. It returns nil if name does not exist.
+	RemoveAll(name string) error
+}
+
+// DirFS returns a WriteFS for the directory dir in the OS filesystem.
+func DirFS(dir string) WriteFS {
+	return &dirFS{
+		FS:  os.DirFS(dir),
+		dir: dir,
+	}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d *dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+func (d *dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := d.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(p, perm)
+}
+
+func (d *dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p, err := d.join("write", name)
+	if err != nil {
+		return err
+	}
+
+	// Older builds hard linked some files to their sources, so the
+	// file is removed instead of written over.
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return os.WriteFile(p, data, perm)
+}
+
+func (d *dirFS) RemoveAll(name string) error {
+	p, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(p)
+}
+
+// MemFS is a WriteFS that keeps files in memory, such as for previews
+// or tests. It is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: make(fstest.MapFS),
+	}
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.files.Open(name)
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if f, ok := m.files[dir]; ok {
+			if !f.Mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+			}
+			continue
+		}
+
+		m.files[dir] = &fstest.MapFile{Mode: fs.ModeDir | perm, ModTime: time.Now()}
+	}
+
+	return nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.files[name]; ok && f.Mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrExist}
+	}
+
+	// Files are replaced rather than changed, so that files opened
+	// earlier keep their contents.
+	m.files[name] = &fstest.MapFile{
+		Data:    append([]byte(nil), data...),
+		Mode:    perm,
+		ModTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for p := range m.files {
+		if isInside(name, p) {
+			delete(m.files, p)
+		}
+	}
+
+	return nil
+}
+
+// Returns whether name is dir or inside it.
+func isInside(dir, name string) bool {
+	return dir == "." || name == dir || strings.HasPrefix(name, dir+"/")
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/JessebotX/bookgen"
+)
+
+// Writes the collection as JSON (see bookgen.CollectionExport and
+// bookgen.BookExport) along with the assets of each book, instead of
+// rendering it with the layouts.
+func (b *builder) renderJSON(c *bookgen.Collection) error {
+	total := 1 + len(c.Books)
+	for _, book := range c.Books {
+		total += len(book.Assets)
+	}
+	b.setTotal(total)
+
+	if err := b.writeJSON("collection.json", c.Export()); err != nil {
+		return fmt.Errorf("failed to write collection JSON file. %w", err)
+	}
+
+	for _, book := range c.Books {
+		bookPath := path.Join("books", book.PageName)
+
+		if err := b.writeJSON(path.Join(bookPath, "book.json"), book.Export()); err != nil {
+			return fmt.Errorf("failed to write book `%v` JSON file. %w", book.PageName, err)
+		}
+
+		if err := b.renderBookAssets(&book, bookPath); err != nil {
+			return fmt.Errorf("failed to write book `%v` assets. %w", book.PageName, err)
+		}
+	}
+
+	return nil
+}
+
+// Write v as JSON, indented unless minifying.
+func (b *builder) writeJSON(name string, v any) error {
+	start := time.Now()
+
+	var data []byte
+	var err error
+	if b.opts.Minify {
+		data, err = json.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	return b.writePage(name, "", append(data, '\n'), start)
+}
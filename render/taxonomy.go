@@ -0,0 +1,131 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"time"
+
+	"github.com/JessebotX/bookgen"
+)
+
+// Templates of taxonomy and term pages used when the layouts have no
+// `_taxonomy.html` or `_term.html`.
+const (
+	defaultTaxonomyTemplate = `<!DOCTYPE html>
+<html lang="{{ .Parent.LanguageCode }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Name }} | {{ .Parent.Title }}</title>
+</head>
+<body>
+<p><a href="../index.html">{{ .Parent.Title }}</a></p>
+<h1>{{ .Name }}</h1>
+<ul>
+{{- range .Terms }}
+<li><a href="{{ .Slug }}/index.html">{{ .Name }}</a></li>
+{{- end }}
+</ul>
+</body>
+</html>
+`
+
+	defaultTermTemplate = `<!DOCTYPE html>
+<html lang="{{ .Parent.Parent.LanguageCode }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Name }} | {{ .Parent.Parent.Title }}</title>
+</head>
+<body>
+<p><a href="../../index.html">{{ .Parent.Parent.Title }}</a> / <a href="../index.html">{{ .Parent.Name }}</a></p>
+<h1>{{ .Name }}</h1>
+{{- if .Books }}
+<ul>
+{{- range .Books }}
+<li><a href="../../books/{{ .PageName }}/index.html">{{ .Title }}</a></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- if .Chapters }}
+<ul>
+{{- range .Chapters }}
+<li><a href="../../books/{{ .Parent.PageName }}/{{ .PageName }}.html">{{ .Title }}</a> ({{ .Parent.Title }})</li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`
+)
+
+// Returns the number of taxonomy and term pages of the collection.
+func taxonomyPages(c *bookgen.Collection) int {
+	total := 0
+	for _, taxonomy := range c.Taxonomies {
+		if len(taxonomy.Terms) > 0 {
+			total += 1 + len(taxonomy.Terms)
+		}
+	}
+
+	return total
+}
+
+// Write a page for each taxonomy of the collection with terms, and a
+// page for each term.
+func (b *builder) renderTaxonomies(c *bookgen.Collection, layouts fs.FS) error {
+	taxonomyTemplate, err := parseTemplateOrDefault(layouts, "_taxonomy.html", defaultTaxonomyTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse taxonomy template. %w", err)
+	}
+
+	termTemplate, err := parseTemplateOrDefault(layouts, "_term.html", defaultTermTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse term template. %w", err)
+	}
+
+	for i := range c.Taxonomies {
+		taxonomy := &c.Taxonomies[i]
+		if len(taxonomy.Terms) == 0 {
+			continue
+		}
+
+		start := time.Now()
+		page, err := executeTemplate(taxonomyTemplate, "_taxonomy.html", taxonomy)
+		if err != nil {
+			return fmt.Errorf("failed to write taxonomy `%v` index file. %w", taxonomy.Name, err)
+		}
+
+		if err := b.writePage(taxonomy.Path(), "text/html", page, start); err != nil {
+			return fmt.Errorf("failed to write taxonomy `%v` index file. %w", taxonomy.Name, err)
+		}
+
+		for _, term := range taxonomy.Terms {
+			start := time.Now()
+			page, err := executeTemplate(termTemplate, "_term.html", term)
+			if err != nil {
+				return fmt.Errorf("failed to write term `%v` of taxonomy `%v`. %w", term.Name, taxonomy.Name, err)
+			}
+
+			if err := b.writePage(term.Path(), "text/html", page, start); err != nil {
+				return fmt.Errorf("failed to write term `%v` of taxonomy `%v`. %w", term.Name, taxonomy.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Parse the template name of the layouts, or text if the layouts do not
+// have it.
+func parseTemplateOrDefault(layouts fs.FS, name, text string) (*template.Template, error) {
+	if _, err := fs.Stat(layouts, name); err == nil {
+		return parseTemplate(layouts, name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
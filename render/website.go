@@ -0,0 +1,449 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/JessebotX/bookgen"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var templateFuncs = template.FuncMap{
+	// Returns a variant of an image scaled down to a width, which is
+	// written to the book's output directory after rendering.
+	"resize": func(img *bookgen.Image, width int) (bookgen.ImageVariant, error) {
+		if img == nil {
+			return bookgen.ImageVariant{}, fmt.Errorf("resize: image is nil")
+		}
+
+		return img.Resize(width), nil
+	},
+
+	// Returns Open Graph, Twitter card and JSON-LD metadata of a
+	// collection, book or chapter for the <head> of its page.
+	"metadata": func(v any) (template.HTML, error) {
+		switch v := v.(type) {
+		case *bookgen.Collection:
+			return v.SocialMetadata(), nil
+		case bookgen.Collection:
+			return v.SocialMetadata(), nil
+		case *bookgen.Book:
+			return v.SocialMetadata(), nil
+		case bookgen.Book:
+			return v.SocialMetadata(), nil
+		case *bookgen.Chapter:
+			return v.SocialMetadata(), nil
+		case bookgen.Chapter:
+			return v.SocialMetadata(), nil
+		}
+
+		return "", fmt.Errorf("metadata: unsupported type %T", v)
+	},
+}
+
+// Parse a template from layouts along with the shared
+// `_template_*.html` templates.
+func parseTemplate(layouts fs.FS, name string) (*template.Template, error) {
+	patterns := []string{name}
+	shared, err := fs.Glob(layouts, "_template_*.html")
+	if err == nil {
+		patterns = append(patterns, shared...)
+	}
+
+	return template.New(name).Funcs(templateFuncs).ParseFS(layouts, patterns...)
+}
+
+func executeTemplate(t *template.Template, name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (b *builder) renderWebsite(c *bookgen.Collection) error {
+	layouts := b.opts.Layouts
+	if layouts == nil {
+		var err error
+		layouts, err = fs.Sub(b.in, path.Clean(filepath.ToSlash(c.Internal.LayoutsDirectory)))
+		if err != nil {
+			return fmt.Errorf("failed to read layouts directory. %w", err)
+		}
+	}
+
+	// Collection index, taxonomy and term pages, and for each book:
+	// index, RSS feed, calendar, chapters and assets
+	total := 1 + taxonomyPages(c)
+	for _, book := range c.Books {
+		total += 2 + len(book.Chapters) + len(book.Assets)
+		if hasCalendar(&book) {
+			total += 1
+		}
+	}
+	if c.Highlighting.Classes {
+		total += 1
+		if c.Highlighting.DarkStyle != "" {
+			total += 2
+		}
+	}
+	b.setTotal(total)
+
+	// ---
+	// Copy global static items into output
+	// ---
+	if err := copyStaticFiles(layouts, b.out, []string{
+		"index.html",
+		"_book.html",
+		"_chapter.html",
+		"_taxonomy.html",
+		"_term.html",
+		"shortcodes",
+	}, []string{
+		"_template_*.html",
+	}); err != nil {
+		return fmt.Errorf("failed to copy files to output. %w", err)
+	}
+
+	// ---
+	// Write syntax highlighting stylesheets
+	// ---
+	if c.Highlighting.Classes {
+		if err := b.renderHighlightingStylesheets(c); err != nil {
+			return fmt.Errorf("failed to write syntax highlighting stylesheets. %w", err)
+		}
+	}
+
+	// ---
+	// Read templates
+	// ---
+	collectionTemplate, err := parseTemplate(layouts, "index.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse collection template. %w", err)
+	}
+
+	bookTemplate, err := parseTemplate(layouts, "_book.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse book template. %w", err)
+	}
+
+	chapterTemplate, err := parseTemplate(layouts, "_chapter.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse chapter template. %w", err)
+	}
+
+	// ---
+	// Collection index
+	// ---
+	start := time.Now()
+	index, err := executeTemplate(collectionTemplate, "index.html", c)
+	if err != nil {
+		return fmt.Errorf("failed to write collection index file. %w", err)
+	}
+
+	if err := b.writePage("index.html", "text/html", index, start); err != nil {
+		return fmt.Errorf("failed to write collection index file. %w", err)
+	}
+
+	// ---
+	// Taxonomies
+	// ---
+	// Rendered before any book assets, so that images resized by
+	// taxonomy templates are included in the variants written
+	if err := b.renderTaxonomies(c, layouts); err != nil {
+		return err
+	}
+
+	// TODO: epub generation
+	for _, book := range c.Books {
+		bookPath := path.Join("books", book.PageName)
+
+		start := time.Now()
+		index, err := executeTemplate(bookTemplate, "_book.html", book)
+		if err != nil {
+			return fmt.Errorf("failed to write book `%v` index file. %w", book.PageName, err)
+		}
+
+		if err := b.writePage(path.Join(bookPath, "index.html"), "text/html", index, start); err != nil {
+			return fmt.Errorf("failed to write book `%v` index file. %w", book.PageName, err)
+		}
+
+		// Render chapters, RSS feed and calendar of releases
+		g := new(errgroup.Group)
+		g.Go(func() error {
+			return b.renderBookRSS(bookPath, &book)
+		})
+		if hasCalendar(&book) {
+			g.Go(func() error {
+				return b.renderBookCalendar(bookPath, &book)
+			})
+		}
+		g.Go(func() error {
+			return b.renderBookChapters(book.Chapters, chapterTemplate, bookPath)
+		})
+		if err := g.Wait(); err != nil {
+			return fmt.Errorf("failed to write book `%v` chapter file. %w", book.PageName, err)
+		}
+
+		// Add assets (e.g. cover image) and resized images to output
+		if err := b.renderBookAssets(&book, bookPath); err != nil {
+			return fmt.Errorf("failed to write book `%v` assets. %w", book.PageName, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *builder) renderBookChapters(chapters []bookgen.Chapter, chapterTemplate *template.Template, bookPath string) error {
+	for _, chapter := range chapters {
+		start := time.Now()
+		page, err := executeTemplate(chapterTemplate, "_chapter.html", chapter)
+		if err != nil {
+			return err
+		}
+
+		if err := b.writePage(path.Join(bookPath, chapter.PageName+".html"), "text/html", page, start); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes `highlighting.css`, which uses the dark style when the reader
+// prefers a dark color scheme, and if a dark style is set,
+// `highlighting-light.css` and `highlighting-dark.css` for themes that
+// let readers choose.
+func (b *builder) renderHighlightingStylesheets(c *bookgen.Collection) error {
+	start := time.Now()
+
+	light, err := c.Highlighting.Stylesheet(c.Highlighting.Style)
+	if err != nil {
+		return err
+	}
+
+	stylesheets := map[string][]byte{
+		"highlighting.css": light,
+	}
+
+	if c.Highlighting.DarkStyle != "" {
+		dark, err := c.Highlighting.Stylesheet(c.Highlighting.DarkStyle)
+		if err != nil {
+			return err
+		}
+
+		combined := slices.Concat(light, []byte("@media (prefers-color-scheme: dark) {\n"), dark, []byte("}\n"))
+
+		stylesheets["highlighting.css"] = combined
+		stylesheets["highlighting-light.css"] = light
+		stylesheets["highlighting-dark.css"] = dark
+	}
+
+	for name, css := range stylesheets {
+		if err := b.writePage(name, "text/css", css, start); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copy the files in layouts into out, except the excluded paths
+// (relative to the root of layouts) and the paths matching the
+// excluded patterns.
+func copyStaticFiles(layouts fs.FS, out WriteFS, relExcludes, relExcludesPatterns []string) error {
+	return fs.WalkDir(layouts, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name == "." {
+			return nil
+		}
+
+		// Check against exclusions
+		excluded := slices.Contains(relExcludes, name)
+		for _, pattern := range relExcludesPatterns {
+			matching, err := path.Match(pattern, name)
+			if err != nil {
+				return err
+			}
+			excluded = excluded || matching
+		}
+
+		if excluded {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return out.MkdirAll(name, DirPerms)
+		}
+
+		data, err := fs.ReadFile(layouts, name)
+		if err != nil {
+			return err
+		}
+
+		return out.WriteFile(name, data, FilePerms)
+	})
+}
+
+// Copies the assets of a book into its output directory at bookPath
+// and writes the resized variants of its images.
+func (b *builder) renderBookAssets(book *bookgen.Book, bookPath string) error {
+	for _, name := range book.Assets {
+		pathNew := path.Join(bookPath, name)
+
+		if err := b.out.MkdirAll(path.Dir(pathNew), DirPerms); err != nil {
+			return err
+		}
+
+		if err := b.ctx.Err(); err != nil {
+			return err
+		}
+
+		var data []byte
+		if book.CoverImage != nil && book.CoverImage.Name == name {
+			data = book.CoverImage.Generated()
+		}
+
+		if data == nil {
+			var err error
+			data, err = fs.ReadFile(b.in, path.Join(bookPath, name))
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := b.out.WriteFile(pathNew, data, FilePerms); err != nil {
+			return err
+		}
+
+		b.logger.Debug("copied asset", "path", pathNew, "bytes", len(data))
+		b.wrote(EventAssetCopied, pathNew, len(data))
+	}
+
+	g := new(errgroup.Group)
+	for _, img := range book.Images() {
+		for _, variant := range img.AllVariants() {
+			g.Go(func() error {
+				return b.writeImageVariant(img, variant, bookPath)
+			})
+		}
+	}
+
+	return g.Wait()
+}
+
+// Write a resized variant of an image. Nothing is written if the
+// variant is the original image or if it is already newer than the
+// original image. The width and encoding settings are part of the
+// name of a variant, so a file of the same name has the same settings.
+func (b *builder) writeImageVariant(img *bookgen.Image, v bookgen.ImageVariant, bookPath string) error {
+	if v.Name == img.Name {
+		return nil
+	}
+
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	name := path.Join(bookPath, v.Name)
+	modTime := img.ModTime()
+	if info, err := fs.Stat(b.out, name); err == nil && !modTime.IsZero() && !info.ModTime().Before(modTime) {
+		return nil
+	}
+
+	data, err := img.EncodeVariant(v)
+	if err != nil {
+		return err
+	}
+
+	if err := b.out.MkdirAll(path.Dir(name), DirPerms); err != nil {
+		return err
+	}
+
+	if err := b.out.WriteFile(name, data, FilePerms); err != nil {
+		return err
+	}
+
+	b.logger.Debug("wrote resized image", "path", name, "bytes", len(data))
+	b.addBytes(len(data))
+
+	return nil
+}
+
+func (b *builder) renderBookRSS(bookPath string, book *bookgen.Book) error {
+	start := time.Now()
+	var f bytes.Buffer
+
+	unescapedBookLink, err := url.JoinPath(book.BaseURL, "index.html")
+	if err != nil {
+		return err
+	}
+	escapedBookLink := unescapedBookLink
+
+	bookTitle := html.EscapeString(book.Title)
+	bookLang := html.EscapeString(book.LanguageCode)
+
+	f.WriteString(`
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+<title>` + bookTitle + `</title>
+<link>` + escapedBookLink + `</link>
+<description>Recent content for ` + book.Title + `</description>
+<language>` + bookLang + `</language>
+<atom:link href="` + escapedBookLink + `" rel="self" type="application/rss+xml" />
+`)
+
+	for _, c := range book.Chapters {
+		unescapedChapterLink, err := url.JoinPath(book.BaseURL, c.PageName+".html")
+		if err != nil {
+			return err
+		}
+		escapedChapterLink := unescapedChapterLink
+
+		chapterTitle := html.EscapeString(c.Title)
+
+		// Written by hand, or else the summary of the content
+		description := chapterTitle + ` now available @ ` + escapedChapterLink
+		if c.Description != "" {
+			description = html.EscapeString(c.Description)
+		} else if c.Summary.HTML != "" {
+			description = html.EscapeString(string(c.Summary.HTML))
+		}
+
+		f.WriteString(`
+<item>
+<title>` + chapterTitle + `</title>
+<link>` + escapedChapterLink + `</link>
+<guid>` + escapedChapterLink + `</guid>
+<description>` + description + `</description>`)
+
+		if !c.DatePublished.IsZero() {
+			chapterDate := c.DatePublished.Format("Mon, 02 Jan 2006 15:04:05 -0700")
+
+			f.WriteString(`
+<pubDate>` + chapterDate + `</pubDate>
+`)
+		}
+
+		f.WriteString(`</item>`)
+	}
+
+	f.WriteString(`</channel></rss>`)
+
+	return b.writePage(path.Join(bookPath, "rss.xml"), "", f.Bytes(), start)
+}
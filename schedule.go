@@ -0,0 +1,124 @@
+package bookgen
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Release is an upcoming chapter of a book (see Book.Releases).
+type Release struct {
+	PageName string
+	Title    string
+	Date     time.Time
+}
+
+// parsedSchedule is a Schedule with its fields checked and parsed.
+type parsedSchedule struct {
+	days     [7]bool // by time.Weekday
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+// Returns nil if the schedule has no days.
+func (s *Schedule) parse() (*parsedSchedule, error) {
+	if len(s.Days) == 0 {
+		return nil, nil
+	}
+
+	p := &parsedSchedule{location: time.UTC}
+	for _, day := range s.Days {
+		i := slices.Index(ScheduleValidDays, strings.ToLower(strings.TrimSpace(day)))
+		if i < 0 {
+			return nil, fmt.Errorf("invalid value `%v` for field `schedule.days`. Must be one of the following options (case-insensitive): %v.", day, strings.Join(ScheduleValidDays, " | "))
+		}
+		p.days[i] = true
+	}
+
+	if strings.TrimSpace(s.Time) != "" {
+		t, err := time.Parse("15:04", strings.TrimSpace(s.Time))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value `%v` for field `schedule.time`. Must be written as HH:MM (e.g. 18:00).", s.Time)
+		}
+		p.hour, p.minute = t.Hour(), t.Minute()
+	}
+
+	if strings.TrimSpace(s.TimeZone) != "" {
+		location, err := time.LoadLocation(strings.TrimSpace(s.TimeZone))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value `%v` for field `schedule.timeZone`. %w", s.TimeZone, err)
+		}
+		p.location = location
+	}
+
+	return p, nil
+}
+
+// Returns the first release of the schedule after t.
+func (p *parsedSchedule) next(t time.Time) time.Time {
+	t = t.In(p.location)
+
+	// The same weekday as t is checked twice, in case the release
+	// time on that day has passed
+	for i := range 8 {
+		date := time.Date(t.Year(), t.Month(), t.Day()+i, p.hour, p.minute, 0, 0, p.location)
+		if p.days[date.Weekday()] && date.After(t) {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
+
+// Set the publication date of each chapter without one to the next
+// release of the schedule after the previous chapter. Chapters must be
+// sorted. Chapters before the first chapter with a date are left
+// without one, with a warning.
+func (b *Book) projectChapterDates() error {
+	schedule, err := b.Schedule.parse()
+	if err != nil || schedule == nil {
+		return err
+	}
+
+	var last time.Time
+	for i := range b.Chapters {
+		c := &b.Chapters[i]
+		if c.DatePublished.IsZero() {
+			if last.IsZero() {
+				c.Warnings = append(c.Warnings, "missing publication date (`published`), which is needed to project the dates of the following chapters from the schedule")
+				continue
+			}
+			c.DatePublished = schedule.next(last)
+			c.projected = true
+		}
+
+		last = c.DatePublished
+	}
+
+	return nil
+}
+
+// Returns the date on which the next chapter is expected after now
+// (see Book.NextRelease). Releases and chapters must be set.
+func (b *Book) nextRelease(now time.Time) time.Time {
+	if len(b.Releases) > 0 {
+		return b.Releases[0].Date
+	}
+
+	schedule, _ := b.Schedule.parse()
+	if schedule == nil || !strings.EqualFold(b.Status, "ongoing") {
+		return time.Time{}
+	}
+
+	// Scheduled chapters may be included (see DecodeOptions.Future)
+	after := now
+	for _, c := range b.Chapters {
+		if c.DatePublished.After(after) {
+			after = c.DatePublished
+		}
+	}
+
+	return schedule.next(after)
+}
@@ -0,0 +1,468 @@
+package bookgen
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Version of JSON Schema that Schema documents use.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document describing the keys of a
+// configuration file or front matter, or a part of one. Only the
+// keywords used by bookgen are supported.
+type Schema struct {
+	Dialect     string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// `string`, `integer`, `number`, `boolean`, `array` or `object`,
+	// or empty for any value.
+	Type string `json:"type,omitempty"`
+
+	Enum    []any    `json:"enum,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+
+	// Strings that match without case. JSON Schema has no enum that
+	// ignores case, so the document has a Pattern instead (see
+	// caseInsensitiveEnum).
+	options []string
+}
+
+// Returns the schema of bookgen.yml (see Collection).
+func CollectionSchema() *Schema {
+	return newSchema(reflect.TypeFor[Collection](), "bookgen.schema.json", "bookgen.yml", "Configuration of a bookgen collection.")
+}
+
+// Returns the schema of bookgen-book.yml (see Book).
+func BookSchema() *Schema {
+	return newSchema(reflect.TypeFor[Book](), "bookgen-book.schema.json", "bookgen-book.yml", "Configuration of a book in a bookgen collection.")
+}
+
+// Returns the schema of the front matter of a chapter (see Chapter).
+func ChapterSchema() *Schema {
+	return newSchema(reflect.TypeFor[Chapter](), "chapter.schema.json", "Chapter front matter", "Front matter of a chapter of a book.")
+}
+
+// Constraints of fields that are checked after decoding, by the name
+// of their type and field.
+var schemaConstraints = map[string]func(s *Schema){
+	"Book.Status": func(s *Schema) {
+		caseInsensitiveEnum(s, BookStatusValidValues)
+	},
+	"Collection.SummaryLength": func(s *Schema) {
+		s.Minimum = ptr(1.0)
+	},
+	"Collection.ConfigFormatVersion": func(s *Schema) {
+		s.Minimum = ptr(0.0)
+		s.Maximum = ptr(float64(latestConfigVersion))
+	},
+	"Schedule.Days": func(s *Schema) {
+		caseInsensitiveEnum(s.Items, ScheduleValidDays)
+	},
+	"Schedule.Time": func(s *Schema) {
+		s.Description = "Time of day as HH:MM."
+		s.Pattern = `^\d{1,2}:\d{2}$`
+	},
+	"ReadingTime.WordsPerMinute": func(s *Schema) {
+		s.Minimum = ptr(1.0)
+	},
+	"ReadingTime.CJKCharactersPerMinute": func(s *Schema) {
+		s.Minimum = ptr(1.0)
+	},
+	"Highlighting.Style":     styleEnum,
+	"Highlighting.DarkStyle": styleEnum,
+	"Highlighting.TabWidth": func(s *Schema) {
+		s.Minimum = ptr(1.0)
+	},
+	"Images.Widths": func(s *Schema) {
+		s.Items.Minimum = ptr(1.0)
+	},
+	"Images.ThumbnailWidth": func(s *Schema) {
+		s.Minimum = ptr(1.0)
+	},
+	"Images.Quality": func(s *Schema) {
+		s.Minimum = ptr(1.0)
+		s.Maximum = ptr(100.0)
+	},
+}
+
+// Restrict a string to options matched without case, with a pattern
+// such as `^([Oo][Nn][Gg][Oo][Ii][Nn][Gg]|...)$`.
+func caseInsensitiveEnum(s *Schema, options []string) {
+	s.options = options
+	s.Description = "One of the following options (case-insensitive): " + strings.Join(options, " | ") + "."
+
+	patterns := make([]string, len(options))
+	for i, option := range options {
+		var b strings.Builder
+		for _, r := range option {
+			if upper, lower := unicode.ToUpper(r), unicode.ToLower(r); upper != lower {
+				b.WriteString("[" + string(upper) + string(lower) + "]")
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		patterns[i] = b.String()
+	}
+	s.Pattern = "^(" + strings.Join(patterns, "|") + ")$"
+}
+
+func styleEnum(s *Schema) {
+	names := styles.Names()
+	slices.Sort(names)
+	for _, name := range names {
+		s.Enum = append(s.Enum, name)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func newSchema(t reflect.Type, id, title, description string) *Schema {
+	defs := make(map[string]*Schema)
+
+	s := structSchema(t, defs)
+	s.Dialect = SchemaDialect
+	s.ID = id
+	s.Title = title
+	s.Description = description
+
+	// Keys of params that are read separately (see paramsOnlyKeys)
+	if t != reflect.TypeFor[Collection]() {
+		s.Properties["published"] = dateSchema("Date of publication.")
+		s.Properties["modified"] = dateSchema("Date of the last modification.")
+		s.Properties["expires"] = dateSchema("Date after which it is excluded from builds.")
+	}
+	s.Properties["extra"] = &Schema{
+		Description: "Custom values for templates.",
+	}
+
+	if len(defs) > 0 {
+		s.Defs = defs
+	}
+
+	return s
+}
+
+// Returns the schema of the keys of a struct. Other structs are added
+// to defs and referenced by their name.
+func structSchema(t reflect.Type, defs map[string]*Schema) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: ptr(false),
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() || slices.Contains(decodedFields, field.Name) {
+			continue
+		}
+
+		property := typeSchema(field.Type, defs)
+		if constrain, ok := schemaConstraints[t.Name()+"."+field.Name]; ok {
+			constrain(property)
+		}
+		s.Properties[lowerCamelCase(field.Name)] = property
+	}
+
+	return s
+}
+
+func typeSchema(t reflect.Type, defs map[string]*Schema) *Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem(), defs)}
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // recursive types
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	}
+
+	return &Schema{}
+}
+
+// Returns the schema of a date in one of DateFormats.
+func dateSchema(description string) *Schema {
+	patterns := make([]string, len(DateFormats))
+	for i, format := range DateFormats {
+		patterns[i] = datePattern(format)
+	}
+
+	return &Schema{
+		Type:        "string",
+		Description: description + " One of the following formats: " + strings.Join(DateFormats, " | ") + ".",
+		Pattern:     "^(" + strings.Join(patterns, "|") + ")$",
+	}
+}
+
+// Convert a time.Layout into a regular expression.
+func datePattern(layout string) string {
+	replacer := strings.NewReplacer(
+		"2006", `\d{4}`,
+		"Z07:00", `(Z|[+-]\d{2}:\d{2})`,
+		"01", `\d{2}`,
+		"02", `\d{2}`,
+		"15", `\d{1,2}`, // time.Parse allows a single digit
+		"04", `\d{2}`,
+		"05", `\d{2}`,
+	)
+
+	return replacer.Replace(layout)
+}
+
+// schemaError is a value that does not match a Schema.
+type schemaError struct {
+	// Path of the key, e.g. `authors[0].name`.
+	key     string
+	message string
+}
+
+// Validate params against the schema, which is a document returned by
+// CollectionSchema, BookSchema or ChapterSchema.
+func (s *Schema) validate(params map[string]any) []schemaError {
+	var errs []schemaError
+	s.validateValue(s, "", params, &errs)
+
+	slices.SortFunc(errs, func(a, b schemaError) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	return errs
+}
+
+func (s *Schema) validateValue(root *Schema, key string, value any, errs *[]schemaError) {
+	if name, ok := strings.CutPrefix(s.Ref, "#/$defs/"); ok {
+		if def := root.Defs[name]; def != nil {
+			def.validateValue(root, key, value, errs)
+		}
+		return
+	}
+
+	fail := func(format string, a ...any) {
+		*errs = append(*errs, schemaError{key: key, message: fmt.Sprintf(format, a...)})
+	}
+
+	// Dates are already decoded in TOML
+	if t, ok := value.(time.Time); ok {
+		value = t.Format(time.RFC3339)
+	}
+
+	if s.Type != "" && !schemaTypeMatches(s.Type, value) {
+		fail("invalid value for key `%v`: expected %v, got %v", key, s.Type, schemaTypeOf(value))
+		return
+	}
+
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		fail("invalid value `%v` for key `%v`. Must be one of the following options: %v", value, key, joinAny(s.Enum, " | "))
+		return
+	}
+
+	if str, ok := value.(string); ok && len(s.options) > 0 && !slices.ContainsFunc(s.options, func(option string) bool {
+		return strings.EqualFold(option, str)
+	}) {
+		fail("invalid value `%v` for key `%v`. Must be one of the following options (case-insensitive): %v", value, key, strings.Join(s.options, " | "))
+		return
+	}
+
+	if s.Pattern != "" {
+		if str, ok := value.(string); ok && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			fail("invalid value `%v` for key `%v`: %v", str, key, s.Description)
+			return
+		}
+	}
+
+	if n, ok := schemaNumber(value); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("invalid value `%v` for key `%v`. Must be at least %v", value, key, *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("invalid value `%v` for key `%v`. Must be at most %v", value, key, *s.Maximum)
+		}
+	}
+
+	switch v := value.(type) {
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validateValue(root, fmt.Sprintf("%v[%v]", key, i), item, errs)
+			}
+		}
+	case map[string]any:
+		for k, item := range v {
+			itemKey := k
+			if key != "" {
+				itemKey = key + "." + k
+			}
+
+			if property := s.property(k); property != nil {
+				property.validateValue(root, itemKey, item, errs)
+				continue
+			}
+
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				message := fmt.Sprintf("unknown key `%v`", itemKey)
+				if suggestion := suggestKey(k, slices.Sorted(maps.Keys(s.Properties))); suggestion != "" {
+					message += fmt.Sprintf(". Did you mean `%v`?", suggestion)
+				}
+				*errs = append(*errs, schemaError{key: itemKey, message: message})
+			}
+		}
+	}
+}
+
+// Returns the schema of the property named key, which is matched
+// without case as when decoding.
+func (s *Schema) property(key string) *Schema {
+	if property, ok := s.Properties[key]; ok {
+		return property
+	}
+
+	for name, property := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return property
+		}
+	}
+
+	return nil
+}
+
+// Returns whether the document has a key at a path such as
+// `highlighting.style`, matched without case. Any key is allowed
+// under a value without properties, such as `extra`.
+func (s *Schema) hasKey(key string) bool {
+	_, ok := s.keySchema(key)
+	return ok
+}
+
+// Returns the schema of the key at a path such as
+// `highlighting.style`, matched without case, and whether the document
+// has the key. The schema is nil for keys under a value without
+// properties, such as `extra.x`, which may have any value.
+func (s *Schema) keySchema(key string) (*Schema, bool) {
+	current := s
+	for _, part := range strings.Split(key, ".") {
+		current = s.resolve(current)
+		if current == nil {
+			return nil, false
+		}
+
+		if current.Properties == nil {
+			return nil, current.Type == ""
+		}
+
+		current = current.property(part)
+		if current == nil {
+			return nil, false
+		}
+	}
+
+	return s.resolve(current), true
+}
+
+// Returns the definition in the document that a schema refers to, or
+// the schema itself if it has no reference.
+func (s *Schema) resolve(schema *Schema) *Schema {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/$defs/"); ok {
+		return s.Defs[name]
+	}
+
+	return schema
+}
+
+func schemaTypeMatches(schemaType string, value any) bool {
+	switch schemaType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		n, ok := schemaNumber(value)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := schemaNumber(value)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+
+	return true
+}
+
+// Returns the name of the JSON Schema type of a decoded value.
+func schemaTypeOf(value any) string {
+	for _, t := range []string{"string", "boolean", "integer", "number", "array", "object"} {
+		if schemaTypeMatches(t, value) {
+			return t
+		}
+	}
+
+	if value == nil {
+		return "null"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaNumber(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+func joinAny(values []any, sep string) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+
+	return strings.Join(s, sep)
+}
@@ -0,0 +1,48 @@
+package bookgen
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCaseInsensitiveEnumPattern(t *testing.T) {
+	schema := BookSchema()
+
+	tests := []struct {
+		key    string
+		values []string
+		valid  bool
+	}{
+		{"status", []string{"completed", "Completed", "ONGOING", "hiAtus"}, true},
+		{"status", []string{"paused", "completed ", "complete"}, false},
+		{"schedule.days", []string{"monday", "Monday", "SUNDAY"}, true},
+		{"schedule.days", []string{"mon", "funday"}, false},
+	}
+
+	for _, test := range tests {
+		s, ok := schema.keySchema(test.key)
+		if !ok || s == nil {
+			t.Fatalf("no schema for key `%v`", test.key)
+		}
+		if s.Items != nil {
+			s = s.Items
+		}
+
+		if len(s.Enum) > 0 {
+			t.Errorf("`%v` has an enum, which editors match with case: %v", test.key, s.Enum)
+		}
+
+		pattern := regexp.MustCompile(s.Pattern)
+		for _, value := range test.values {
+			if got := pattern.MatchString(value); got != test.valid {
+				t.Errorf("`%v`: pattern matches %q = %v, want %v", test.key, value, got, test.valid)
+			}
+
+			var errs []schemaError
+			s.validateValue(schema, test.key, value, &errs)
+			if got := len(errs) == 0; got != test.valid {
+				t.Errorf("`%v`: %q is valid = %v, want %v (%v)", test.key, value, got, test.valid, errs)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package bookgen
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// source is a filesystem that a collection, book or chapter is
+// decoded from. Names in it are slash-separated paths relative to its
+// root, as in fs.FS.
+type source struct {
+	fsys fs.FS
+
+	// Directory of fsys in the OS filesystem, only used for showing
+	// paths in messages. Empty if fsys is not an OS directory.
+	root string
+}
+
+func osSource(dir string) source {
+	return source{
+		fsys: os.DirFS(dir),
+		root: dir,
+	}
+}
+
+// Returns name as it should be shown in messages.
+func (s source) path(name string) string {
+	if s.root == "" {
+		return name
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+// Returns name relative to dir, or false if name is not inside dir.
+// Both are slash-separated paths in the same filesystem.
+func relativePath(dir, name string) (string, bool) {
+	dir = path.Clean(dir)
+	name = path.Clean(name)
+
+	if dir == "." {
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return "", false
+		}
+		return name, true
+	}
+
+	if name == dir {
+		return ".", true
+	}
+
+	rel, ok := strings.CutPrefix(name, dir+"/")
+	return rel, ok
+}
@@ -0,0 +1,166 @@
+package bookgen
+
+import (
+	"bytes"
+	"html"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tdewolff/parse/v2"
+	htmlparse "github.com/tdewolff/parse/v2/html"
+)
+
+// Stats represents statistics of the text of a chapter or book,
+// without markup.
+type Stats struct {
+	// Words of the text outside of code blocks and their titles. Each
+	// Chinese and Japanese character and Korean syllable counts as a
+	// word.
+	Words int
+
+	// Characters of the text outside of code blocks and their titles,
+	// excluding whitespace.
+	Characters int
+
+	// Estimated time to read the text (see ReadingTime).
+	ReadingTime time.Duration
+
+	CodeBlocks int
+	Images     int
+}
+
+// Returns the estimated reading time in whole minutes, rounded up, for
+// templates (e.g. `12 min read`).
+func (s Stats) ReadingMinutes() int {
+	return int(math.Ceil(s.ReadingTime.Minutes()))
+}
+
+// Returns the sum of both statistics.
+func (s Stats) Add(other Stats) Stats {
+	return Stats{
+		Words:       s.Words + other.Words,
+		Characters:  s.Characters + other.Characters,
+		ReadingTime: s.ReadingTime + other.ReadingTime,
+		CodeBlocks:  s.CodeBlocks + other.CodeBlocks,
+		Images:      s.Images + other.Images,
+	}
+}
+
+// Elements whose text is not counted.
+var statsSkippedTags = []string{"pre", "button", "script", "style", "template"}
+
+// Returns the statistics of content in a language.
+func newStats(content Content, languageCode string, settings ReadingTime) Stats {
+	s := Stats{CodeBlocks: content.codeBlocks}
+
+	// Depth of elements whose text is not read, such as code blocks
+	// and the copy buttons of code blocks
+	skipDepth := 0
+
+	// Titles of code blocks are figcaptions, which are only skipped
+	// by their class (see codeBlockWrapper)
+	tag := ""
+	inCodeBlockTitle := false
+	lexer := htmlparse.NewLexer(parse.NewInputBytes([]byte(content.HTML)))
+	for {
+		tt, data := lexer.Next()
+		if tt == htmlparse.ErrorToken {
+			break
+		}
+
+		switch tt {
+		case htmlparse.StartTagToken:
+			tag = string(bytes.ToLower(lexer.Text()))
+			if tag == "img" {
+				s.Images++
+			}
+
+			if slices.Contains(statsSkippedTags, tag) {
+				skipDepth++
+			}
+		case htmlparse.AttributeToken:
+			if tag == "figcaption" && !inCodeBlockTitle && string(bytes.ToLower(lexer.Text())) == "class" &&
+				slices.Contains(strings.Fields(string(bytes.Trim(lexer.AttrVal(), `"'`))), "code-block-title") {
+				inCodeBlockTitle = true
+				skipDepth++
+			}
+		case htmlparse.EndTagToken:
+			endTag := string(bytes.ToLower(lexer.Text()))
+			if skipDepth > 0 && slices.Contains(statsSkippedTags, endTag) {
+				skipDepth--
+			} else if inCodeBlockTitle && endTag == "figcaption" {
+				inCodeBlockTitle = false
+				skipDepth--
+			}
+		case htmlparse.TextToken:
+			if skipDepth == 0 {
+				s.countText(html.UnescapeString(string(data)))
+			}
+		}
+	}
+
+	perMinute := settings.WordsPerMinute
+	if isCJKLanguage(languageCode) {
+		perMinute = settings.CJKCharactersPerMinute
+	}
+
+	if perMinute > 0 {
+		s.ReadingTime = time.Duration(float64(s.Words) / float64(perMinute) * float64(time.Minute))
+	}
+
+	return s
+}
+
+// Count the words and characters of text.
+func (s *Stats) countText(text string) {
+	inWord := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+
+		s.Characters++
+
+		if isCJK(r) {
+			s.Words++
+			inWord = false
+			continue
+		}
+
+		// Punctuation on its own (e.g. a dash between spaces) is not a
+		// word
+		if !inWord && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+			s.Words++
+			inWord = true
+		}
+	}
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Returns whether a language (e.g. `ja` or `zh-Hant`) is read by
+// character instead of by word.
+func isCJKLanguage(languageCode string) bool {
+	language, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	language, _, _ = strings.Cut(language, "_")
+
+	return language == "zh" || language == "ja" || language == "ko"
+}
+
+// Returns the reading speeds of the book's collection, or the default
+// speeds if there is none.
+func (b *Book) readingTime() ReadingTime {
+	if b != nil && b.Parent != nil {
+		return b.Parent.ReadingTime
+	}
+
+	var r ReadingTime
+	r.InitializeDefaults()
+	return r
+}
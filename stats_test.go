@@ -0,0 +1,38 @@
+package bookgen
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestChapterStats(t *testing.T) {
+	chapter := "---\ntitle: One\npublished: 2024-01-01\n---\n" +
+		"One two three.\n\n" +
+		"```go {linenos=table title=\"Words in a title\"}\nx := 1\ny := 2\n```\n\n" +
+		"    indented code\n\n" +
+		"```flowchart\na -> b\n```\n\n" +
+		"![Image](https://example.com/image.png) four\n"
+
+	fsys := fstest.MapFS{
+		"bookgen.yml":              {Data: []byte("title: Collection\nbaseURL: https://example.com/\n")},
+		"books/a/bookgen-book.yml": {Data: []byte("title: A\n")},
+		"books/a/chapters/one.md":  {Data: []byte(chapter)},
+	}
+
+	c, err := DecodeCollectionFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := c.Books[0].Chapters[0]
+	if n := strings.Count(string(got.Content.HTML), "<pre"); n < 3 {
+		t.Fatalf("expected a table of line numbers with more than one <pre>, got %d in %s", n, got.Content.HTML)
+	}
+
+	want := Stats{Words: 4, Characters: 16, CodeBlocks: 2, Images: 1}
+	got.Stats.ReadingTime = 0
+	if got.Stats != want {
+		t.Errorf("got %+v, want %+v", got.Stats, want)
+	}
+}
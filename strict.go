@@ -0,0 +1,555 @@
+package bookgen
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-viper/mapstructure/v2"
+
+	"github.com/JessebotX/bookgen/internal/meta"
+
+	"github.com/goccy/go-yaml/ast"
+	yamlparser "github.com/goccy/go-yaml/parser"
+)
+
+// ConfigError describes a problem with a key in a configuration file
+// or in the front matter of a chapter.
+type ConfigError struct {
+	File string
+
+	// Position of the key in File, or 0 if it is unknown.
+	Line   int
+	Column int
+
+	// Path of the key (e.g. `highlighting.style` or
+	// `authors[0].name`).
+	Key string
+
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%v: %v", e.File, e.Message)
+	}
+
+	return fmt.Sprintf("%v:%v:%v: %v", e.File, e.Line, e.Column, e.Message)
+}
+
+// Keys of params that are read separately instead of being decoded
+// into a field. `extra` holds custom values for templates.
+var paramsOnlyKeys = []string{"published", "modified", "expires", "extra"}
+
+// Keys that other static site generators use, and the key to use
+// instead.
+var keyAliases = map[string]string{
+	"date":        "published",
+	"publishdate": "published",
+	"lastmod":     "modified",
+	"updated":     "modified",
+	"params":      "extra",
+}
+
+// Fields that are set while decoding, and cannot be set in a
+// configuration file or front matter.
+var decodedFields = []string{
+	"Params", "Parent", "Previous", "Next", "PageName", "Books",
+	"Chapters", "Content", "CoverImage", "Assets", "Warnings",
+	"DatePublished", "DateModified", "DateExpires", "Releases", "NextRelease",
+	"Stats", "Summary", "Taxonomies",
+}
+
+// configFile is a configuration file or the front matter of a chapter
+// that params are read from.
+type configFile struct {
+	// Path of the file shown in messages.
+	path string
+
+	// Source of the params in format, used to find the positions of
+	// keys.
+	format meta.Format
+	data   []byte
+
+	// Number of lines in the file before data (e.g. the `---` line
+	// of front matter).
+	lineOffset int
+}
+
+// configDecoder decodes the params of configuration files or front
+// matter into a Collection, Book or Chapter.
+type configDecoder struct {
+	// Files that the params were merged from, in order.
+	files []configFile
+
+	// Overrides set in the params after merging files.
+	overrides []Override
+
+	// Unknown keys are errors instead of warnings, and values are not
+	// converted between types (e.g. "1" into 1).
+	strict bool
+}
+
+// Where a key is set: a position in a file, or an override.
+type keyPosition struct {
+	file   string
+	line   int
+	column int
+
+	// Index of the file or override, in the order they are applied.
+	order int
+}
+
+// Decode params into the fields of result. Unknown keys are returned
+// as warnings, or as errors in strict mode. Each problem is a
+// ConfigError.
+func (d *configDecoder) decode(params map[string]any, result any) ([]string, error) {
+	var metadata mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata:         &metadata,
+		Result:           result,
+		WeaklyTypedInput: !d.strict,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	positions := d.positions()
+	resultType := reflect.TypeOf(result).Elem()
+
+	// Fields set while decoding are unknown keys, instead of being
+	// overwritten by the params.
+	var decodedKeys []string
+	input := make(map[string]any, len(params))
+	for key, value := range params {
+		if slices.ContainsFunc(decodedFields, func(field string) bool { return strings.EqualFold(field, key) }) {
+			decodedKeys = append(decodedKeys, key)
+			continue
+		}
+		input[key] = value
+	}
+
+	var errs []error
+	if err := decoder.Decode(input); err != nil {
+		for _, decodeErr := range decodeErrors(err) {
+			key := keyPath(decodeErr.Name())
+			errs = append(errs, d.newError(positions, key, fmt.Sprintf("invalid value for key `%v`: %v", key, decodeErr.Unwrap())))
+		}
+
+		if len(errs) == 0 {
+			return nil, err
+		}
+
+		return nil, errors.Join(errs...)
+	}
+
+	unknownKeys := slices.DeleteFunc(append(metadata.Unused, decodedKeys...), func(key string) bool {
+		return slices.Contains(paramsOnlyKeys, key)
+	})
+
+	// Report keys in the order they are written
+	slices.SortFunc(unknownKeys, func(a, b string) int {
+		pa, pb := positions[strings.ToLower(keyPath(a))], positions[strings.ToLower(keyPath(b))]
+		return cmp.Or(pa.order-pb.order, pa.line-pb.line, strings.Compare(a, b))
+	})
+
+	var warnings []string
+	for _, unknownKey := range unknownKeys {
+		key := keyPath(unknownKey)
+		message := fmt.Sprintf("unknown key `%v`", key)
+
+		parent, name := "", key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			parent, name = key[:i], key[i+1:]
+		}
+
+		candidates := configKeys(resultType, parent)
+		if parent == "" {
+			candidates = append(candidates, paramsOnlyKeys...)
+		}
+
+		suggestion := suggestKey(name, candidates)
+		if alias, ok := keyAliases[strings.ToLower(name)]; ok && parent == "" {
+			suggestion = alias
+		}
+
+		if suggestion != "" {
+			message += fmt.Sprintf(". Did you mean `%v`?", suggestion)
+		}
+
+		configErr := d.newError(positions, key, message)
+		if d.strict {
+			errs = append(errs, configErr)
+		} else {
+			warnings = append(warnings, configErr.Error())
+		}
+	}
+
+	return warnings, errors.Join(errs...)
+}
+
+func (d *configDecoder) newError(positions map[string]keyPosition, key, message string) *ConfigError {
+	e := &ConfigError{
+		Key:     key,
+		Message: message,
+	}
+
+	if len(d.files) > 0 {
+		e.File = d.files[0].path
+	}
+
+	// Values in lists have no position of their own, so the position
+	// of the list is used instead.
+	for k := strings.ToLower(key); k != ""; k = k[:max(strings.LastIndexAny(k, ".["), 0)] {
+		if position, ok := positions[k]; ok {
+			e.File = position.file
+			e.Line = position.line
+			e.Column = position.column
+			return e
+		}
+	}
+
+	// Overrides only have the position of the key they set, e.g.
+	// `--set params.x=1` for the unknown key `params`
+	for k, position := range positions {
+		if strings.HasPrefix(k, strings.ToLower(key)+".") && position.line == 0 {
+			e.File = position.file
+			break
+		}
+	}
+
+	return e
+}
+
+// Returns where every key is set by its lower case key path. Keys set
+// in later files or by overrides replace those before them.
+func (d *configDecoder) positions() map[string]keyPosition {
+	positions := make(map[string]keyPosition)
+	for i, file := range d.files {
+		for key, position := range keyPositions(file.format, file.data) {
+			positions[key] = keyPosition{
+				file:   file.path,
+				line:   position[0] + file.lineOffset,
+				column: position[1],
+				order:  i,
+			}
+		}
+	}
+
+	for i, override := range d.overrides {
+		key := strings.ToLower(override.Key)
+		for k := range positions {
+			if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+				delete(positions, k)
+			}
+		}
+		positions[key] = keyPosition{file: override.Origin, order: len(d.files) + i}
+	}
+
+	return positions
+}
+
+// Returns the innermost mapstructure errors in err, which are joined
+// for each field.
+func decodeErrors(err error) []*mapstructure.DecodeError {
+	var errs []*mapstructure.DecodeError
+
+	switch e := err.(type) {
+	case *mapstructure.DecodeError:
+		if inner := decodeErrors(e.Unwrap()); len(inner) > 0 {
+			return inner
+		}
+		return []*mapstructure.DecodeError{e}
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			errs = append(errs, decodeErrors(inner)...)
+		}
+	case interface{ Unwrap() error }:
+		return decodeErrors(e.Unwrap())
+	}
+
+	return errs
+}
+
+var keyPathIndexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// Convert a mapstructure field name (e.g. `Authors[0].Name`) into the
+// path of a key as it is written in YAML (`authors[0].name`).
+func keyPath(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		index := ""
+		if loc := keyPathIndexPattern.FindStringIndex(part); loc != nil {
+			part, index = part[:loc[0]], part[loc[0]:]
+		}
+		parts[i] = lowerCamelCase(part) + index
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Convert a Go field name into the name of its key, e.g. `BaseURL`
+// into `baseURL` and `IDs` into `ids`.
+func lowerCamelCase(s string) string {
+	runes := []rune(s)
+
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+
+	// Keep the last upper case letter of an initialism followed by a
+	// word, e.g. `URLPath` into `urlPath`.
+	if n > 1 && n < len(runes) && !(n == len(runes)-1 && runes[n] == 's') {
+		n--
+	}
+
+	for i := range n {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
+
+// Returns the keys that can be set in the struct at parent (a key
+// path) of t.
+func configKeys(t reflect.Type, parent string) []string {
+	if parent != "" {
+		for _, part := range strings.Split(keyPathIndexPattern.ReplaceAllString(parent, ""), ".") {
+			for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+
+			if t.Kind() != reflect.Struct {
+				return nil
+			}
+
+			field, ok := t.FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, part)
+			})
+			if !ok {
+				return nil
+			}
+			t = field.Type
+		}
+	}
+
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() || slices.Contains(decodedFields, field.Name) {
+			continue
+		}
+		keys = append(keys, lowerCamelCase(field.Name))
+	}
+
+	return keys
+}
+
+// Returns the candidate most similar to key, or an empty string if
+// none are similar enough.
+func suggestKey(key string, candidates []string) string {
+	best := ""
+	bestDistance := max(2, len(key)/3) + 1
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(candidate))
+
+		// Also suggest keys that contain or are contained in key, e.g.
+		// `cover` for `coverImageName`.
+		if distance >= bestDistance && len(key) >= 4 && (strings.Contains(strings.ToLower(candidate), strings.ToLower(key)) || strings.Contains(strings.ToLower(key), strings.ToLower(candidate))) {
+			distance = bestDistance - 1
+		}
+
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	return best
+}
+
+// Number of single character edits needed to change a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// Returns the line and column of every key in data by its lower case
+// key path (e.g. `authors[0].name`).
+func keyPositions(format meta.Format, data []byte) map[string][2]int {
+	switch format {
+	case meta.FormatTOML:
+		return tomlKeyPositions(data)
+	default:
+		// JSON is also YAML
+		return yamlKeyPositions(data)
+	}
+}
+
+// Returns the line and column of every key in a YAML document by its
+// lower case key path (e.g. `authors[0].name`).
+func yamlKeyPositions(data []byte) map[string][2]int {
+	positions := make(map[string][2]int)
+
+	file, err := yamlparser.ParseBytes(data, 0)
+	if err != nil {
+		return positions
+	}
+
+	var walk func(prefix string, node ast.Node)
+	walk = func(prefix string, node ast.Node) {
+		switch n := node.(type) {
+		case *ast.MappingNode:
+			for _, value := range n.Values {
+				walk(prefix, value)
+			}
+		case *ast.MappingValueNode:
+			token := n.Key.GetToken()
+			if token == nil {
+				return
+			}
+
+			key := strings.ToLower(token.Value)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+
+			positions[key] = [2]int{token.Position.Line, token.Position.Column}
+			walk(key, n.Value)
+		case *ast.SequenceNode:
+			for i, value := range n.Values {
+				walk(prefix+"["+strconv.Itoa(i)+"]", value)
+			}
+		case *ast.TagNode:
+			walk(prefix, n.Value)
+		case *ast.AnchorNode:
+			walk(prefix, n.Value)
+		}
+	}
+
+	for _, doc := range file.Docs {
+		walk("", doc.Body)
+	}
+
+	return positions
+}
+
+var tomlTablePattern = regexp.MustCompile(`^\[\[?\s*([^\]]+?)\s*\]\]?`)
+
+// Returns the line and column of every key in a TOML document by its
+// lower case key path. Only keys at the start of a line are found,
+// which is enough for configuration files and front matter.
+func tomlKeyPositions(data []byte) map[string][2]int {
+	positions := make(map[string][2]int)
+	arrays := make(map[string]int)
+
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		column := len(line) - len(trimmed) + 1
+
+		if strings.HasPrefix(trimmed, "[") {
+			// Table (`[name]`) or array of tables (`[[name]]`)
+			match := tomlTablePattern.FindStringSubmatch(trimmed)
+			if match == nil {
+				continue
+			}
+
+			table = tomlKeyPath(match[1])
+			if strings.HasPrefix(trimmed, "[[") {
+				index := arrays[table]
+				arrays[table]++
+				table += "[" + strconv.Itoa(index) + "]"
+			}
+			positions[table] = [2]int{i + 1, column}
+			continue
+		}
+
+		key, _, ok := strings.Cut(trimmed, "=")
+		if !ok || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		key = tomlKeyPath(key)
+		if table != "" {
+			key = table + "." + key
+		}
+		positions[key] = [2]int{i + 1, column}
+	}
+
+	return positions
+}
+
+// Convert a TOML key (e.g. `authors . "name"`) into a lower case key
+// path.
+func tomlKeyPath(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.Trim(strings.TrimSpace(part), `"'`))
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Returns the front matter of a markdown file (see meta.Format), along
+// with its format and the number of lines in the file before it.
+func frontMatter(source []byte) ([]byte, meta.Format, int) {
+	s := strings.ReplaceAll(string(source), "\r\n", "\n")
+	first, rest, ok := strings.Cut(s, "\n")
+	if !ok {
+		return nil, "", 0
+	}
+
+	format := meta.FormatFromDelimiter([]byte(first))
+	switch format {
+	case "":
+		return nil, "", 0
+	case meta.FormatJSON:
+		// The braces are part of the JSON object
+		end := strings.Index(rest, "\n}")
+		if end < 0 {
+			return nil, "", 0
+		}
+		return []byte(first + "\n" + rest[:end+2] + "\n"), format, 0
+	}
+
+	delimiter := strings.TrimSpace(first)
+	end := strings.Index("\n"+rest, "\n"+delimiter)
+	if end < 0 {
+		return nil, "", 0
+	}
+
+	return []byte(rest[:end]), format, 1
+}
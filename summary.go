@@ -0,0 +1,316 @@
+package bookgen
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/tdewolff/parse/v2"
+	htmlparse "github.com/tdewolff/parse/v2/html"
+	"github.com/yuin/goldmark/ast"
+)
+
+// Summary represents a short excerpt of the content of a chapter or
+// book, e.g. for chapter listings, feeds and social metadata.
+type Summary struct {
+	// HTML of the excerpt, with every element closed.
+	HTML template.HTML
+
+	// Text of the excerpt without markup.
+	Text string
+
+	// Whether the content continues after the excerpt, e.g. to show a
+	// `Read more` link.
+	Truncated bool
+}
+
+// Marker separating the summary of markdown content from the rest, on
+// a line of its own.
+const summaryMarker = "<!--more-->"
+
+// Elements left out of automatic summaries, as they cannot be read
+// out of context.
+var summarySkippedTags = []string{
+	"pre", "button", "script", "style", "template", "figure", "table",
+	"h1", "h2", "h3", "h4", "h5", "h6",
+}
+
+// Elements separating blocks of text, which are separated by a space
+// in Summary.Text.
+var summaryBlockTags = []string{
+	"p", "div", "br", "li", "dt", "dd", "blockquote", "tr", "td", "th",
+	"h1", "h2", "h3", "h4", "h5", "h6",
+}
+
+// Elements without an end tag.
+var voidTags = []string{
+	"area", "base", "br", "col", "embed", "hr", "img", "input", "link",
+	"meta", "source", "track", "wbr",
+}
+
+// Returns the summary of content: the content before the
+// `<!--more-->` marker if there is one, or else its first words
+// outside of headings, code blocks, figures and tables.
+func newSummary(content Content, words int) Summary {
+	if content.summary != "" {
+		return Summary{
+			HTML:      content.summary,
+			Text:      summaryText(string(content.summary)),
+			Truncated: true,
+		}
+	}
+
+	var b bytes.Buffer
+	var open []summaryElement // elements that are not closed yet
+	truncated := false
+	count := 0
+	skipDepth := 0
+
+	lexer := htmlparse.NewLexer(parse.NewInputBytes([]byte(content.HTML)))
+	for !truncated {
+		tt, data := lexer.Next()
+		if tt == htmlparse.ErrorToken {
+			break
+		}
+
+		switch tt {
+		case htmlparse.StartTagToken, htmlparse.EndTagToken:
+			tag := string(bytes.ToLower(lexer.Text()))
+			if slices.Contains(summarySkippedTags, tag) {
+				if tt == htmlparse.StartTagToken {
+					skipDepth++
+				} else if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+
+			if skipDepth > 0 || count >= words {
+				continue
+			}
+
+			if tt == htmlparse.StartTagToken {
+				if !slices.Contains(voidTags, tag) {
+					open = append(open, summaryElement{tag: tag, start: b.Len()})
+				} else if tag != "br" && tag != "wbr" {
+					setContent(open)
+				}
+			} else if i := slices.IndexFunc(open, func(e summaryElement) bool { return e.tag == tag }); i >= 0 {
+				// The last element with the tag
+				for j := len(open) - 1; j > i; j-- {
+					if open[j].tag == tag {
+						i = j
+						break
+					}
+				}
+
+				element := open[i]
+				open = open[:i]
+				if !element.content {
+					b.Truncate(element.start)
+					continue
+				}
+			}
+		case htmlparse.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+
+			text, n := cutWords(data, words-count)
+			count += n
+			if len(bytes.TrimSpace(data[len(text):])) > 0 {
+				truncated = true
+			}
+			data = text
+
+			// Skipped elements between spaces would leave two spaces
+			if len(data) > 0 && isSpaceByte(data[0]) && b.Len() > 0 && isSpaceByte(b.Bytes()[b.Len()-1]) {
+				data = bytes.TrimLeft(data, " \t\r\n")
+			}
+
+			if len(bytes.TrimSpace(data)) > 0 {
+				setContent(open)
+			}
+		case htmlparse.MathToken:
+			// Math is kept whole as a word, as its text alternative
+			// cannot be cut
+			if skipDepth > 0 {
+				continue
+			}
+			if count >= words {
+				truncated = true
+				continue
+			}
+			count++
+			setContent(open)
+		case htmlparse.SvgToken, htmlparse.CommentToken:
+			continue
+		default:
+			// Attributes and the end of start tags
+			if skipDepth > 0 || count >= words {
+				continue
+			}
+		}
+
+		b.Write(data)
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		if !open[i].content {
+			b.Truncate(open[i].start)
+			continue
+		}
+		b.WriteString("</" + open[i].tag + ">")
+	}
+
+	summary := strings.TrimSpace(b.String())
+
+	return Summary{
+		HTML:      template.HTML(summary),
+		Text:      summaryText(summary),
+		Truncated: truncated,
+	}
+}
+
+// summaryElement is an element of a summary that is not closed yet.
+type summaryElement struct {
+	tag string
+
+	// Length of the summary before the start tag, which it is cut to
+	// if the element has no content.
+	start int
+
+	// Whether the element has text or media, or else it is left out
+	// (e.g. the wrapper of a diagram).
+	content bool
+}
+
+// Mark the open elements as having content.
+func setContent(open []summaryElement) {
+	for i := range open {
+		open[i].content = true
+	}
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Returns text cut after the nth word, and the number of words in the
+// returned text. Each Chinese and Japanese character and Korean
+// syllable is a word, as in Stats.
+func cutWords(text []byte, n int) ([]byte, int) {
+	count := 0
+	inWord := false
+	for i, r := range string(text) {
+		isWord := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if unicode.IsSpace(r) || isCJK(r) {
+			if count == n {
+				return text[:i], count
+			}
+			inWord = false
+		}
+
+		if isCJK(r) {
+			count++
+			continue
+		}
+
+		if !inWord && isWord {
+			if count == n {
+				return text[:i], count
+			}
+			count++
+			inWord = true
+		}
+	}
+
+	return text, count
+}
+
+// Returns the text of HTML, with blocks separated by a space and
+// whitespace collapsed.
+func summaryText(s string) string {
+	var b strings.Builder
+	lexer := htmlparse.NewLexer(parse.NewInputString(s))
+	for {
+		tt, data := lexer.Next()
+		if tt == htmlparse.ErrorToken {
+			break
+		}
+
+		switch tt {
+		case htmlparse.TextToken:
+			b.WriteString(html.UnescapeString(string(data)))
+		case htmlparse.MathToken:
+			b.WriteString(mathText(data))
+		case htmlparse.StartTagToken, htmlparse.EndTagToken:
+			if slices.Contains(summaryBlockTags, string(bytes.ToLower(lexer.Text()))) {
+				b.WriteString(" ")
+			}
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Returns the text alternative of a MathML element: its TeX annotation,
+// or else its `alttext` attribute.
+func mathText(data []byte) string {
+	s := string(data)
+	if _, annotation, ok := strings.Cut(s, "<annotation"); ok {
+		if _, annotation, ok = strings.Cut(annotation, ">"); ok {
+			annotation, _, _ = strings.Cut(annotation, "</annotation>")
+			return html.UnescapeString(annotation)
+		}
+	}
+
+	if _, alt, ok := strings.Cut(s, `alttext="`); ok {
+		alt, _, _ = strings.Cut(alt, `"`)
+		return html.UnescapeString(alt)
+	}
+
+	return ""
+}
+
+// Returns the `<!--more-->` marker if it is a block of document (i.e.
+// on a line of its own), or nil.
+func findSummaryMarker(document ast.Node, source []byte) ast.Node {
+	for n := document.FirstChild(); n != nil; n = n.NextSibling() {
+		block, ok := n.(*ast.HTMLBlock)
+		if !ok {
+			continue
+		}
+
+		var text bytes.Buffer
+		lines := block.Lines()
+		for i := range lines.Len() {
+			segment := lines.At(i)
+			text.Write(segment.Value(source))
+		}
+		if block.HasClosure() {
+			text.Write(block.ClosureLine.Value(source))
+		}
+
+		if strings.EqualFold(strings.Join(strings.Fields(text.String()), ""), summaryMarker) {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// Returns the summary length of the book's collection, or the default
+// length if there is none.
+func (b *Book) summaryLength() int {
+	if b != nil && b.Parent != nil {
+		return b.Parent.SummaryLength
+	}
+
+	var c Collection
+	c.InitializeDefaults()
+	return c.SummaryLength
+}
@@ -0,0 +1,246 @@
+package bookgen
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Names of the taxonomies of a Collection, in the order of
+// Collection.Taxonomies.
+const (
+	TaxonomyTags    = "tags"
+	TaxonomyAuthors = "authors"
+	TaxonomySeries  = "series"
+)
+
+// Taxonomy groups the books and chapters of a collection by the values
+// of a field, such as Book.Tags.
+type Taxonomy struct {
+	Parent *Collection `json:"-"`
+
+	// One of TaxonomyTags, TaxonomyAuthors or TaxonomySeries, which is
+	// also the directory of its pages in the output.
+	Name string
+
+	// Sorted by slug.
+	Terms []*Term
+}
+
+// Returns the path of the taxonomy's page, relative to the root of the
+// collection's output.
+func (t *Taxonomy) Path() string {
+	return path.Join(t.Name, "index.html")
+}
+
+// Returns the term with a name or slug, or nil if there is none.
+func (t *Taxonomy) Term(name string) *Term {
+	if term := t.find(name); term != nil {
+		return term
+	}
+
+	for _, term := range t.Terms {
+		if term.Slug == name {
+			return term
+		}
+	}
+
+	return nil
+}
+
+// Term is a value of a Taxonomy, such as a tag, with the books and
+// chapters that have it.
+type Term struct {
+	Parent *Taxonomy `json:"-"`
+
+	// Name as first written in a book or chapter.
+	Name string
+
+	// Name used in the path of the term's page (see Slugify). Names
+	// that only differ in case and spacing are the same term. When
+	// different names have the same slug (e.g. `C++` and `C`), the
+	// later one is given a suffix (`c-2`).
+	Slug string
+
+	// In the order of Collection.Books, or by number in a series.
+	Books []*Book
+
+	// Chapters with the term that are not already included by their
+	// book, e.g. chapters by a guest author.
+	Chapters []*Chapter
+}
+
+// Returns the path of the term's page, relative to the root of the
+// collection's output.
+func (t *Term) Path() string {
+	return path.Join(t.Parent.Name, t.Slug, "index.html")
+}
+
+// Returns the name of a taxonomy term in lower case, with every
+// sequence of characters other than letters and numbers replaced by a
+// dash (e.g. `Science Fiction` into `science-fiction`).
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return b.String()
+}
+
+// Returns the taxonomy with a name (e.g. TaxonomyTags), or nil if there
+// is none.
+func (c *Collection) Taxonomy(name string) *Taxonomy {
+	for i := range c.Taxonomies {
+		if c.Taxonomies[i].Name == name {
+			return &c.Taxonomies[i]
+		}
+	}
+
+	return nil
+}
+
+// Group the books and chapters of the collection into
+// Collection.Taxonomies. Books must be decoded.
+func (c *Collection) buildTaxonomies() {
+	tags := newTaxonomy(c, TaxonomyTags)
+	authors := newTaxonomy(c, TaxonomyAuthors)
+	series := newTaxonomy(c, TaxonomySeries)
+
+	for i := range c.Books {
+		b := &c.Books[i]
+
+		for _, tag := range b.Tags {
+			tags.addBook(tag, b)
+		}
+
+		for _, author := range b.Authors {
+			authors.addBook(author.Name, b)
+		}
+
+		for j := range b.Chapters {
+			for _, author := range b.Chapters[j].Authors {
+				key := termKey(author.Name)
+				if !slices.ContainsFunc(b.Authors, func(a Author) bool { return termKey(a.Name) == key }) {
+					authors.addChapter(author.Name, &b.Chapters[j])
+				}
+			}
+		}
+
+		if b.Series.Name != "" {
+			series.addBook(b.Series.Name, b)
+		}
+	}
+
+	for _, term := range series.Terms {
+		slices.SortStableFunc(term.Books, func(x, y *Book) int {
+			return cmp.Compare(x.Series.Number, y.Series.Number)
+		})
+	}
+
+	c.Taxonomies = []Taxonomy{*tags, *authors, *series}
+
+	// Terms point to the taxonomies in the collection
+	for i := range c.Taxonomies {
+		for _, term := range c.Taxonomies[i].Terms {
+			term.Parent = &c.Taxonomies[i]
+		}
+	}
+}
+
+func newTaxonomy(c *Collection, name string) *Taxonomy {
+	return &Taxonomy{Parent: c, Name: name, Terms: make([]*Term, 0)}
+}
+
+// Returns the name of a term in lower case with spacing collapsed,
+// which is the same for names of the same term.
+func termKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Returns the term with a name, or nil if there is none.
+func (t *Taxonomy) find(name string) *Term {
+	key := termKey(name)
+	for _, term := range t.Terms {
+		if termKey(term.Name) == key {
+			return term
+		}
+	}
+
+	return nil
+}
+
+// Returns the term with a name, adding it if it does not exist. A new
+// term whose slug is already used by another term is given a numbered
+// suffix, with a warning added to the collection.
+func (t *Taxonomy) term(name string) *Term {
+	if term := t.find(name); term != nil {
+		return term
+	}
+
+	name = strings.TrimSpace(name)
+	base := Slugify(name)
+	slug := base
+	var other *Term
+	for n := 2; ; n++ {
+		i, found := slices.BinarySearchFunc(t.Terms, slug, func(term *Term, slug string) int {
+			return strings.Compare(term.Slug, slug)
+		})
+
+		if found {
+			if other == nil {
+				other = t.Terms[i]
+			}
+			slug = fmt.Sprintf("%v-%v", base, n)
+			continue
+		}
+
+		if other != nil {
+			t.Parent.Warnings = append(t.Parent.Warnings, fmt.Sprintf("%v `%v` and `%v` have the same slug `%v`, so the page of `%v` is `%v`", t.Name, other.Name, name, base, name, path.Join(t.Name, slug)))
+		}
+
+		term := &Term{
+			Name:     name,
+			Slug:     slug,
+			Books:    make([]*Book, 0),
+			Chapters: make([]*Chapter, 0),
+		}
+		t.Terms = slices.Insert(t.Terms, i, term)
+
+		return term
+	}
+}
+
+func (t *Taxonomy) addBook(name string, b *Book) {
+	if Slugify(name) == "" {
+		return
+	}
+
+	term := t.term(name)
+	if !slices.Contains(term.Books, b) {
+		term.Books = append(term.Books, b)
+	}
+}
+
+func (t *Taxonomy) addChapter(name string, c *Chapter) {
+	if Slugify(name) == "" {
+		return
+	}
+
+	term := t.term(name)
+	if !slices.Contains(term.Chapters, c) {
+		term.Chapters = append(term.Chapters, c)
+	}
+}
@@ -0,0 +1,166 @@
+package bookgen
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/JessebotX/bookgen/internal/meta"
+)
+
+// Validate the configuration files and the front matter of chapters in
+// a structured directory against CollectionSchema, BookSchema and
+// ChapterSchema, without decoding the rest of the collection. The
+// configuration files of environment (see DecodeOptions.Environment)
+// are validated as well, if it is not empty.
+//
+// Each problem is returned as a ConfigError. The error is only for
+// files that cannot be read.
+func ValidateCollection(workingDir, environment string) ([]*ConfigError, error) {
+	return validateCollection(osSource(workingDir), environment)
+}
+
+// ValidateCollectionFS is like ValidateCollection, but reads the
+// structured directory from the root of fsys.
+func ValidateCollectionFS(fsys fs.FS, environment string) ([]*ConfigError, error) {
+	return validateCollection(source{fsys: fsys}, environment)
+}
+
+func validateCollection(src source, environment string) ([]*ConfigError, error) {
+	v := validator{src: src, environment: environment}
+
+	// ---
+	// Validate collection
+	// ---
+	if err := v.validateConfig(".", "bookgen", configKindCollection, CollectionSchema()); err != nil {
+		return nil, fmt.Errorf("collection: %w", err)
+	}
+
+	items, err := fs.ReadDir(src.fsys, "books")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("collection: failed to read books directory %v. %w", src.path("books"), err)
+	}
+
+	// ---
+	// Validate books
+	// ---
+	bookSchema := BookSchema()
+	chapterSchema := ChapterSchema()
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+
+		dir := path.Join("books", item.Name())
+		if err := v.validateConfig(dir, "bookgen-book", configKindBook, bookSchema); err != nil {
+			return nil, fmt.Errorf("book `%v`: %w", item.Name(), err)
+		}
+
+		chaptersDir := path.Join(dir, "chapters")
+		chapters, err := fs.ReadDir(src.fsys, chaptersDir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("book `%v`: failed to read chapters directory at `%v`. %w", item.Name(), src.path(chaptersDir), err)
+		}
+
+		for _, chapter := range chapters {
+			name := path.Join(chaptersDir, chapter.Name())
+			if chapter.IsDir() {
+				// Chapter bundle
+				name = path.Join(name, "index.md")
+			} else if !strings.HasSuffix(chapter.Name(), ".md") {
+				continue
+			}
+
+			data, err := fs.ReadFile(src.fsys, name)
+			if errors.Is(err, fs.ErrNotExist) && chapter.IsDir() {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("book `%v`: failed to read file at `%v`. %w", item.Name(), src.path(name), err)
+			}
+
+			frontMatter, format, lineOffset := frontMatter(data)
+			if format == "" {
+				continue
+			}
+			v.validateFile(configFile{path: src.path(name), format: format, data: frontMatter, lineOffset: lineOffset}, configKindChapter, chapterSchema)
+		}
+	}
+
+	slices.SortStableFunc(v.errs, func(a, b *ConfigError) int {
+		return cmp.Or(strings.Compare(a.File, b.File), a.Line-b.Line, a.Column-b.Column)
+	})
+
+	return v.errs, nil
+}
+
+// validator holds the state of a single validation.
+type validator struct {
+	src         source
+	environment string
+	errs        []*ConfigError
+
+	// Configuration format version of the collection, which the files
+	// are migrated from before they are validated, as when decoding.
+	version int
+}
+
+// Validate the configuration file named base in dir, and the file of
+// the environment if there is one.
+func (v *validator) validateConfig(dir, base string, kind configKind, schema *Schema) error {
+	bases := []string{base}
+	if v.environment != "" {
+		bases = append(bases, base+"."+v.environment)
+	}
+
+	for i, base := range bases {
+		name, format, err := findConfigFile(v.src, dir, base)
+		if i > 0 && errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		data, err := fs.ReadFile(v.src.fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to read file `%v`. %w", v.src.path(name), err)
+		}
+
+		v.validateFile(configFile{path: v.src.path(name), format: format, data: data}, kind, schema)
+	}
+
+	return nil
+}
+
+func (v *validator) validateFile(file configFile, kind configKind, schema *Schema) {
+	var params map[string]any
+	if err := meta.Unmarshal(file.format, file.data, &params); err != nil {
+		v.errs = append(v.errs, &ConfigError{
+			File:    file.path,
+			Message: fmt.Sprintf("failed to decode %v. %v", strings.ToUpper(string(file.format)), err),
+		})
+		return
+	}
+
+	config := configDecoder{files: []configFile{file}}
+	positions := config.positions()
+
+	if kind == configKindCollection {
+		if _, ok := params[paramKey(params, "configFormatVersion")]; ok {
+			version, err := configVersion(params)
+			if err != nil {
+				v.errs = append(v.errs, config.newError(positions, "configFormatVersion", err.Error()))
+				return
+			}
+			v.version = version
+		}
+	}
+	migrateParams(kind, v.version, params)
+
+	for _, e := range schema.validate(params) {
+		v.errs = append(v.errs, config.newError(positions, e.key, e.message))
+	}
+}